@@ -0,0 +1,54 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Quotes the value as a single POSIX shell word
+func ShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "'\\''") + "'"
+}
+
+// Converts the decoded YAML or JSON map to a map with string keys
+func ToStringMap(value interface{}) (map[string]interface{}, bool) {
+	switch value.(type) {
+	case map[string]interface{}:
+		return value.(map[string]interface{}), true
+	case map[interface{}]interface{}:
+		var out map[string]interface{} = make(map[string]interface{})
+		for key, val := range value.(map[interface{}]interface{}) {
+			out[fmt.Sprintf("%v", key)] = val
+		}
+		return out, true
+	case map[string]string:
+		var out map[string]interface{} = make(map[string]interface{})
+		for key, val := range value.(map[string]string) {
+			out[key] = val
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// Parses a duration given as Go duration string ("30s", "1m30s") or as seconds (30, "30")
+func ParseDurationValue(key string, value interface{}) (time.Duration, error) {
+	var elemValType string = fmt.Sprintf("%T", value)
+	if elemValType == "string" {
+		text := fmt.Sprintf("%v", value)
+		if seconds, err := strconv.Atoi(text); err == nil {
+			return time.Duration(seconds) * time.Second, nil
+		}
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return 0, errors.New("Error parsing command: " + key + ", cause: " + err.Error())
+		}
+		return duration, nil
+	} else if elemValType == "int" {
+		return time.Duration(value.(int)) * time.Second, nil
+	}
+	return 0, errors.New("Unable to parse command: " + key + ", with aguments of type " + elemValType + ", expected type string or int")
+}
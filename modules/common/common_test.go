@@ -0,0 +1,81 @@
+package common
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellQuote(t *testing.T) {
+	var cases []struct {
+		value    string
+		expected string
+	} = []struct {
+		value    string
+		expected string
+	}{
+		{"plain", "'plain'"},
+		{"", "''"},
+		{"two words", "'two words'"},
+		{"it's", "'it'\\''s'"},
+		{"''", "''\\'''\\'''"},
+		{"$HOME `id` \"x\" \\n", "'$HOME `id` \"x\" \\n'"},
+	}
+	for _, tc := range cases {
+		if value := ShellQuote(tc.value); value != tc.expected {
+			t.Errorf("ShellQuote(%q) = %s, expected %s", tc.value, value, tc.expected)
+		}
+	}
+}
+
+func TestShellQuoteRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	// The shell must read back the exact value as a single word
+	for _, value := range []string{"it's", "a'b'c", "' ; rm -rf / ; '", "$(id) `id` $HOME", "line\nbreak"} {
+		output, err := exec.Command("sh", "-c", "printf '%s' "+ShellQuote(value)).Output()
+		if err != nil {
+			t.Errorf("sh -c printf %s unexpected error: %v", ShellQuote(value), err)
+			continue
+		}
+		if string(output) != value {
+			t.Errorf("sh read back %q, expected %q", string(output), value)
+		}
+	}
+}
+
+func TestParseDurationValue(t *testing.T) {
+	var cases []struct {
+		value    interface{}
+		expected time.Duration
+		message  string
+	} = []struct {
+		value    interface{}
+		expected time.Duration
+		message  string
+	}{
+		{30, 30 * time.Second, ""},
+		{"45", 45 * time.Second, ""},
+		{"1m30s", 90 * time.Second, ""},
+		{"soon", 0, "Error parsing command: test.timeout"},
+		{1.5, 0, "expected type string or int"},
+	}
+	for _, tc := range cases {
+		value, err := ParseDurationValue("test.timeout", tc.value)
+		if tc.message != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.message) {
+				t.Errorf("ParseDurationValue(%v) error = %v, expected to contain %q", tc.value, err, tc.message)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDurationValue(%v) unexpected error: %v", tc.value, err)
+			continue
+		}
+		if value != tc.expected {
+			t.Errorf("ParseDurationValue(%v) = %v, expected %v", tc.value, value, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package copy
+
+import (
+	"errors"
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"strings"
+	"time"
+)
+
+// Session variable prefix of the backup paths, when copy.backupVar is not given
+var DEFAULT_BACKUP_VAR string = "copy.backup"
+
+// Timestamp layout of the backup file names, as in /etc/app.conf.20200102150405.bak
+var BACKUP_TIMESTAMP_LAYOUT string = "20060102150405"
+
+// Copies the existing remote file, keeping mode and owner, to a timestamped sibling,
+// the transfer then overwrites the original so it is never missing when the transfer
+// fails. The backup path is registered in the session, as <backupVar> for the last
+// backup and as <backupVar>.<remote path> for each replaced file
+func (copyCmd *copyCommand) backupFile(remotePath string, stamp time.Time) (string, error) {
+	backupPath := remotePath + "." + stamp.Format(BACKUP_TIMESTAMP_LAYOUT) + ".bak"
+	output, err := copyCmd.client.Script("cp -p " + common.ShellQuote(remotePath) + " " + common.ShellQuote(backupPath)).ExecuteWithFullOutput()
+	if err != nil {
+		return "", errors.New("Unable to backup remote file " + remotePath + ", cause: " + err.Error() + ", output: " + strings.TrimSpace(string(output)))
+	}
+	if copyCmd.session != nil {
+		backupVar := copyCmd.BackupVar
+		if backupVar == "" {
+			backupVar = DEFAULT_BACKUP_VAR
+		}
+		copyCmd.session.SetVar(backupVar, backupPath)
+		copyCmd.session.SetVar(backupVar+"."+remotePath, backupPath)
+	}
+	return backupPath, nil
+}
@@ -0,0 +1,124 @@
+package copy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Number of remote files hashed by a single remote command
+var CHECKSUM_BATCH_SIZE int = 100
+
+/*
+* Copy outcome, as count of transferred, skipped and backed up files
+ */
+type copyStats struct {
+	Changed   int
+	Unchanged int
+	Backups   int
+}
+
+/*
+* Single file to be copied, with its local and remote paths
+ */
+type copyFile struct {
+	Local  string
+	Remote string
+}
+
+func localChecksum(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Lists the source files and their remote paths, plus the remote folders of a folder copy
+func listFiles(src string, dest string) ([]copyFile, []string, error) {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return nil, nil, errors.New("Source file/folder doesn't exists...")
+	}
+	var files []copyFile = make([]copyFile, 0)
+	var folders []string = make([]string, 0)
+	if !fi.IsDir() {
+		files = append(files, copyFile{Local: src, Remote: dest})
+		return files, folders, nil
+	}
+	err = filepath.Walk(src, func(filePath string, info os.FileInfo, errWalk error) error {
+		if errWalk != nil {
+			return errWalk
+		}
+		rel, errRel := filepath.Rel(src, filePath)
+		if errRel != nil {
+			return errRel
+		}
+		remote := path.Join(dest, filepath.ToSlash(rel))
+		if info.IsDir() {
+			folders = append(folders, remote)
+		} else {
+			files = append(files, copyFile{Local: filePath, Remote: remote})
+		}
+		return nil
+	})
+	return files, folders, err
+}
+
+// Reads the SHA-256 checksums of the remote files, missing or unreadable files are left out
+func (copyCmd *copyCommand) remoteChecksums(paths []string) map[string]string {
+	var sums map[string]string = make(map[string]string)
+	for start := 0; start < len(paths); start += CHECKSUM_BATCH_SIZE {
+		end := start + CHECKSUM_BATCH_SIZE
+		if end > len(paths) {
+			end = len(paths)
+		}
+		var quoted []string = make([]string, 0)
+		for _, remote := range paths[start:end] {
+			quoted = append(quoted, common.ShellQuote(remote))
+		}
+		output, _ := copyCmd.client.Script("sha256sum " + strings.Join(quoted, " ") + " 2>/dev/null; true").ExecuteWithFullOutput()
+		for _, line := range strings.Split(string(output), "\n") {
+			// Format: <64 hex digits><space><space or '*'><path>
+			if len(line) > 66 && line[64] == ' ' {
+				sums[line[66:]] = line[:64]
+			}
+		}
+	}
+	return sums
+}
+
+// Creates the remote folders receiving the changed files, folder copies always
+// create the destination as the folder transfer did, while the parent folders of
+// single files are created only when requested
+func (copyCmd *copyCommand) prepareFolders(isDir bool, folders []string, changed []copyFile, create bool) error {
+	var dirs []string = make([]string, 0)
+	if isDir {
+		for _, folder := range folders {
+			dirs = append(dirs, common.ShellQuote(folder))
+		}
+	} else if create {
+		for _, file := range changed {
+			dirs = append(dirs, common.ShellQuote(path.Dir(file.Remote)))
+		}
+	}
+	if len(dirs) == 0 {
+		return nil
+	}
+	output, err := copyCmd.client.Script("mkdir -p " + strings.Join(dirs, " ")).ExecuteWithFullOutput()
+	if err != nil {
+		return errors.New("Unable to create destination folders, cause: " + err.Error() + ", output: " + strings.TrimSpace(string(output)))
+	}
+	return nil
+}
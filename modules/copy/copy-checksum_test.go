@@ -0,0 +1,75 @@
+package copy
+
+import (
+	"github.com/hellgate75/go-deploy/net/generic"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var SUM_A string = strings.Repeat("a", 64)
+var SUM_B string = strings.Repeat("b", 64)
+
+/*
+* Remote script returning a fixed output
+ */
+type fakeScript struct {
+	generic.RemoteScript
+	output string
+}
+
+func (script *fakeScript) ExecuteWithFullOutput() ([]byte, error) {
+	return []byte(script.output), nil
+}
+
+/*
+* Network client answering every command with the same output
+ */
+type fakeClient struct {
+	generic.NetworkClient
+	output   string
+	commands []string
+}
+
+func (client *fakeClient) Script(command string) generic.RemoteScript {
+	client.commands = append(client.commands, command)
+	return &fakeScript{output: client.output}
+}
+
+func TestRemoteChecksums(t *testing.T) {
+	var cases []struct {
+		output   string
+		expected map[string]string
+	} = []struct {
+		output   string
+		expected map[string]string
+	}{
+		{SUM_A + "  /etc/app.conf\n", map[string]string{"/etc/app.conf": SUM_A}},
+		{SUM_A + " */opt/app/bin\n" + SUM_B + "  /opt/app/with space.txt\n", map[string]string{"/opt/app/bin": SUM_A, "/opt/app/with space.txt": SUM_B}},
+		{SUM_A + "  /etc/app.conf", map[string]string{"/etc/app.conf": SUM_A}},
+		{"sha256sum: /etc/missing: No such file or directory\n" + SUM_B + "  /etc/other\n", map[string]string{"/etc/other": SUM_B}},
+		{SUM_A[:60] + "  /etc/short\n", map[string]string{}},
+		{SUM_A + "  \n", map[string]string{}},
+		{"", map[string]string{}},
+	}
+	for _, tc := range cases {
+		copyCmd := &copyCommand{client: &fakeClient{output: tc.output}}
+		sums := copyCmd.remoteChecksums([]string{"/etc/app.conf"})
+		if !reflect.DeepEqual(sums, tc.expected) {
+			t.Errorf("remoteChecksums with output %q = %v, expected %v", tc.output, sums, tc.expected)
+		}
+	}
+}
+
+func TestRemoteChecksumsBatches(t *testing.T) {
+	client := &fakeClient{}
+	copyCmd := &copyCommand{client: client}
+	var paths []string = make([]string, CHECKSUM_BATCH_SIZE+1)
+	for index := range paths {
+		paths[index] = "/srv/file"
+	}
+	copyCmd.remoteChecksums(paths)
+	if len(client.commands) != 2 {
+		t.Errorf("remoteChecksums of %d paths ran %d commands, expected 2", len(paths), len(client.commands))
+	}
+}
@@ -0,0 +1,97 @@
+package copy
+
+import (
+	"errors"
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"github.com/hellgate75/go-deploy-modules/modules/privilege"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Accepted user and group names or numeric IDs for copy.owner and copy.group
+var OWNER_REGEXP *regexp.Regexp = regexp.MustCompile("^([A-Za-z_][A-Za-z0-9_.-]*\\$?|[0-9]+)$")
+
+func isValidOwner(value string) bool {
+	return OWNER_REGEXP.MatchString(value)
+}
+
+// Ownership change command, empty when neither owner nor group are given
+func (copyCmd *copyCommand) ownershipCommand(targets []string) string {
+	var command string
+	if copyCmd.Owner != "" && copyCmd.Group != "" {
+		command = "chown " + common.ShellQuote(copyCmd.Owner+":"+copyCmd.Group)
+	} else if copyCmd.Owner != "" {
+		command = "chown " + common.ShellQuote(copyCmd.Owner)
+	} else if copyCmd.Group != "" {
+		command = "chgrp " + common.ShellQuote(copyCmd.Group)
+	} else {
+		return ""
+	}
+	if copyCmd.Recursive {
+		command = strings.Replace(command, " ", " -R ", 1)
+	}
+	for _, target := range targets {
+		command += " " + common.ShellQuote(target)
+	}
+	return command
+}
+
+// Applies owner and group to the destination, recursively or to the destination,
+// the copied folders and all the source files, unchanged ones included, so that a
+// wrong remote ownership is fixed also when the content already matches
+func (copyCmd *copyCommand) applyOwnership(dest string, folders []string, files []copyFile) error {
+	if copyCmd.Owner == "" && copyCmd.Group == "" {
+		return nil
+	}
+	var targets []string = []string{dest}
+	if !copyCmd.Recursive {
+		for _, folder := range folders {
+			if folder != dest {
+				targets = append(targets, folder)
+			}
+		}
+		for _, file := range files {
+			if file.Remote != dest {
+				targets = append(targets, file.Remote)
+			}
+		}
+	}
+	for start := 0; start < len(targets); start += CHECKSUM_BATCH_SIZE {
+		end := start + CHECKSUM_BATCH_SIZE
+		if end > len(targets) {
+			end = len(targets)
+		}
+		if err := copyCmd.runPrivileged(copyCmd.ownershipCommand(targets[start:end])); err != nil {
+			return errors.New("Unable to change owner/group of " + dest + ", cause: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// Runs the command as the connecting user and, when not allowed, again escalated
+// to root with the configured escalation and password
+func (copyCmd *copyCommand) runPrivileged(command string) error {
+	if _, err := copyCmd.client.Script(command).ExecuteWithFullOutput(); err == nil {
+		return nil
+	}
+	password, err := privilege.Password(copyCmd.session, copyCmd.PasswordVar)
+	if err != nil {
+		return err
+	}
+	var askPass string
+	if password != "" {
+		id := copyCmd.uuid + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+		askPass, err = privilege.UploadAskPass(copyCmd.client, id, password)
+		if err != nil {
+			return err
+		}
+		defer privilege.RemoveAskPass(copyCmd.client, askPass)
+	}
+	output, err := copyCmd.client.Script(privilege.Wrap(command, "root", askPass)).ExecuteWithFullOutput()
+	if err != nil {
+		return errors.New(err.Error() + ", output: " + strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package copy
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Copies the list items with at most Parallel items in flight,
+// all the failures are reported together in list order
+func (copyCmd *copyCommand) copyItemsParallel() error {
+	var failures []error = make([]error, len(copyCmd.WithList))
+	var semaphore chan bool = make(chan bool, copyCmd.Parallel)
+	var group sync.WaitGroup
+	for index, listItem := range copyCmd.WithList {
+		semaphore <- true
+		group.Add(1)
+		go func(index int, item string) {
+			defer group.Done()
+			defer func() {
+				<-semaphore
+			}()
+			failures[index] = copyCmd.copyItem(item, true)
+		}(index, listItem)
+	}
+	group.Wait()
+	var messages []string = make([]string, 0)
+	for _, failure := range failures {
+		if failure != nil {
+			messages = append(messages, failure.Error())
+		}
+	}
+	if len(messages) > 0 {
+		return errors.New(strings.Join(messages, "\n"))
+	}
+	return nil
+}
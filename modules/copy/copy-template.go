@@ -0,0 +1,139 @@
+package copy
+
+import (
+	"bytes"
+	"errors"
+	"github.com/hellgate75/go-deploy/types/defaults"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+/*
+* Data available to the copy templates, as in:
+*   listen {{ .Host.IpAddress }}:{{ .Vars.port }}
+*   password {{ var "db.password" | quote }}
+ */
+type templateData struct {
+	Item string
+	Host defaults.HostValue
+	Vars map[string]string
+}
+
+// Helper functions available to the copy templates, the session variable
+// lookup 'var' is added per command
+var TEMPLATE_FUNCS template.FuncMap = template.FuncMap{
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+	"trim":     strings.TrimSpace,
+	"contains": strings.Contains,
+	"quote":    strconv.Quote,
+	"replace": func(old string, new string, value string) string {
+		return strings.ReplaceAll(value, old, new)
+	},
+	"split": func(sep string, value string) []string {
+		return strings.Split(value, sep)
+	},
+	"join": func(sep string, values []string) string {
+		return strings.Join(values, sep)
+	},
+	"default": func(fallback string, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+	"indent": func(spaces int, value string) string {
+		pad := strings.Repeat(" ", spaces)
+		return pad + strings.ReplaceAll(value, "\n", "\n"+pad)
+	},
+}
+
+func (copyCmd *copyCommand) templateData(item string) templateData {
+	var vars map[string]string = make(map[string]string)
+	if copyCmd.session != nil {
+		for _, varKey := range copyCmd.WithVars {
+			varValue, err := copyCmd.session.GetVar(varKey)
+			if err == nil {
+				vars[varKey] = varValue
+			}
+		}
+	}
+	return templateData{
+		Item: item,
+		Host: copyCmd.host,
+		Vars: vars,
+	}
+}
+
+func (copyCmd *copyCommand) templateFuncs() template.FuncMap {
+	var funcs template.FuncMap = template.FuncMap{}
+	for name, fn := range TEMPLATE_FUNCS {
+		funcs[name] = fn
+	}
+	funcs["var"] = func(name string) (string, error) {
+		if copyCmd.session == nil {
+			return "", errors.New("no session available, reading variable: " + name)
+		}
+		return copyCmd.session.GetVar(name)
+	}
+	return funcs
+}
+
+// Renders the source file, or every file in the source folder, into a local temporary
+// folder keeping the source base name. It returns the rendered path and the temporary
+// folder, which must be removed by the caller
+func (copyCmd *copyCommand) renderTemplate(src string, item string) (string, string, error) {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return "", "", errors.New("Source file/folder doesn't exists...")
+	}
+	tmpDir, err := ioutil.TempDir("", "go-deploy-template-")
+	if err != nil {
+		return "", "", err
+	}
+	target := filepath.Join(tmpDir, filepath.Base(src))
+	data := copyCmd.templateData(item)
+	funcs := copyCmd.templateFuncs()
+	if !fi.IsDir() {
+		err = renderFile(src, target, fi.Mode(), data, funcs)
+	} else {
+		err = filepath.Walk(src, func(path string, info os.FileInfo, errWalk error) error {
+			if errWalk != nil {
+				return errWalk
+			}
+			rel, errRel := filepath.Rel(src, path)
+			if errRel != nil {
+				return errRel
+			}
+			if info.IsDir() {
+				return os.MkdirAll(filepath.Join(target, rel), info.Mode().Perm()|0700)
+			}
+			return renderFile(path, filepath.Join(target, rel), info.Mode(), data, funcs)
+		})
+	}
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return "", "", err
+	}
+	return target, tmpDir, nil
+}
+
+func renderFile(src string, dest string, mode os.FileMode, data templateData, funcs template.FuncMap) error {
+	content, err := ioutil.ReadFile(src)
+	if err != nil {
+		return errors.New("Unable to read template file " + src + ", cause: " + err.Error())
+	}
+	tmpl, err := template.New(filepath.Base(src)).Funcs(funcs).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return errors.New("Unable to parse template file " + src + ", cause: " + err.Error())
+	}
+	var buffer bytes.Buffer
+	if err = tmpl.Execute(&buffer, data); err != nil {
+		return errors.New("Unable to render template file " + src + ", cause: " + err.Error())
+	}
+	return ioutil.WriteFile(dest, buffer.Bytes(), mode.Perm())
+}
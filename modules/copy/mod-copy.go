@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gookit/color"
+	"github.com/hellgate75/go-deploy-modules/modules/privilege"
+	"github.com/hellgate75/go-deploy-modules/modules/redact"
 	"os"
 	"github.com/hellgate75/go-tcp-common/log"
 	"github.com/hellgate75/go-deploy/modules/meta"
@@ -27,6 +29,17 @@ type copyCommand struct {
 	DestinationDir string
 	FilePerm     	os.FileMode
 	CreateDest     bool
+	Template       bool
+	Backup         bool
+	BackupVar      string
+	Owner          string
+	Group          string
+	Recursive      bool
+	Escalation     string
+	PasswordVar    string
+	Parallel       int
+	SecretVars     []string
+	NoLog          bool
 	WithVars       []string
 	WithList       []string
 	host           defaults.HostValue
@@ -68,93 +81,143 @@ func (copyCmd *copyCommand) Run() error {
 	//Logger.Warnf("Copy Command command not implemented, copy command data: %s", copyCmd.String())
 	var sourceDir string = copyCmd.SourceDir
 	var destinationDir string = copyCmd.DestinationDir
-	var createDestination bool = copyCmd.CreateDest
-
-	transfer := copyCmd.client.FileTranfer()
 
 	if copyCmd.WithList != nil && len(copyCmd.WithList) > 0 {
-		for _, listItem := range copyCmd.WithList {
-			if strings.Index(sourceDir, "{{ item }}") < 0 {
-				if strings.Index(destinationDir, "{{ item }}") < 0 {
-					err = errors.New("Neither Source nor Destination folder contain scalable variable '{{ item }}'")
-					break
-				}
+		if strings.Index(sourceDir, "{{ item }}") < 0 {
+			if strings.Index(destinationDir, "{{ item }}") < 0 {
+				return errors.New("Neither Source nor Destination folder contain scalable variable '{{ item }}'")
 			}
-			sourceDirCopy := strings.ReplaceAll(sourceDir, "{{ item }}", listItem)
-			destinationDirCopy := strings.ReplaceAll(destinationDir, "{{ item }}", listItem)
-			if copyCmd.WithVars != nil && len(copyCmd.WithVars) > 0 {
-				for _, varKey := range copyCmd.WithVars {
-					varValue, varValueErr := copyCmd.session.GetVar(varKey)
-					if varValueErr == nil {
-						sourceDirCopy = strings.ReplaceAll(sourceDirCopy, "{{ "+varKey+" }}", varValue)
-						destinationDirCopy = strings.ReplaceAll(destinationDirCopy, "{{ "+varKey+" }}", varValue)
-					}
-					if copyCmd._logger != nil {
-						copyCmd._logger.Debugf("List Item: %s", listItem)
-						copyCmd._logger.Debugf("Source Folder: %s", sourceDirCopy)
-						copyCmd._logger.Debugf("Destination Folder: %s", destinationDirCopy)
-						copyCmd._logger.Debugf("Create Destination Folder: %v", createDestination)
-					} else {
-						color.LightYellow.Printf("List Item: %s\n", listItem)
-						color.LightYellow.Printf("Source Folder: %s\n", sourceDirCopy)
-						color.LightYellow.Printf("Destination Folder: %s\n", destinationDirCopy)
-						color.LightYellow.Printf("Create Destination Folder: %v\n", createDestination)
-					}
-					
-					errX := copySourceToDest(copyCmd, transfer, sourceDirCopy, destinationDirCopy, createDestination)
-					if errX != nil {
-						err = errX
-						break
-					}
+		}
+		if copyCmd.Parallel > 1 {
+			err = copyCmd.copyItemsParallel()
+		} else {
+			for _, listItem := range copyCmd.WithList {
+				errX := copyCmd.copyItem(listItem, true)
+				if errX != nil {
+					err = errX
+					break
 				}
 			}
 		}
 	} else {
-		if copyCmd.WithVars != nil && len(copyCmd.WithVars) > 0 {
-			for _, varKey := range copyCmd.WithVars {
-				varValue, varValueErr := copyCmd.session.GetVar(varKey)
-				if varValueErr == nil {
-					sourceDir = strings.ReplaceAll(sourceDir, "{{ "+varKey+" }}", varValue)
-					destinationDir = strings.ReplaceAll(destinationDir, "{{ "+varKey+" }}", varValue)
-				}
+		err = copyCmd.copyItem("", false)
+	}
+	copyCmd.started = false
+	copyCmd.finished = true
+	return err
+}
+
+// Copies the source to the destination, resolving list item and session variables
+func (copyCmd *copyCommand) copyItem(listItem string, withItem bool) error {
+	sourceDirCopy := strings.ReplaceAll(copyCmd.SourceDir, "{{ item }}", listItem)
+	destinationDirCopy := strings.ReplaceAll(copyCmd.DestinationDir, "{{ item }}", listItem)
+	if copyCmd.WithVars != nil && len(copyCmd.WithVars) > 0 {
+		for _, varKey := range copyCmd.WithVars {
+			varValue, varValueErr := copyCmd.session.GetVar(varKey)
+			if varValueErr == nil {
+				sourceDirCopy = strings.ReplaceAll(sourceDirCopy, "{{ "+varKey+" }}", varValue)
+				destinationDirCopy = strings.ReplaceAll(destinationDirCopy, "{{ "+varKey+" }}", varValue)
 			}
 		}
+	}
+	// Parallel items log at the same time, so each line tells its list item
+	var prefix string
+	if withItem {
+		prefix = copyCmd.mask("Item: "+listItem) + ", "
+	}
+	if copyCmd._logger != nil {
+		copyCmd._logger.Debugf("%sSource Folder: %s", prefix, copyCmd.mask(sourceDirCopy))
+		copyCmd._logger.Debugf("%sDestination Folder: %s", prefix, copyCmd.mask(destinationDirCopy))
+		copyCmd._logger.Debugf("%sCreate Destination Folder: %v", prefix, copyCmd.CreateDest)
+		copyCmd._logger.Debugf("%sTemplate: %v", prefix, copyCmd.Template)
+	} else {
+		color.LightYellow.Printf("%sSource Folder: %s\n", prefix, copyCmd.mask(sourceDirCopy))
+		color.LightYellow.Printf("%sDestination Folder: %s\n", prefix, copyCmd.mask(destinationDirCopy))
+		color.LightYellow.Printf("%sCreate Destination Folder: %v\n", prefix, copyCmd.CreateDest)
+		color.LightYellow.Printf("%sTemplate: %v\n", prefix, copyCmd.Template)
+	}
+	var errX error
+	if copyCmd.Template {
+		rendered, tmpDir, errR := copyCmd.renderTemplate(sourceDirCopy, listItem)
+		if errR == nil {
+			defer os.RemoveAll(tmpDir)
+			sourceDirCopy = rendered
+		}
+		errX = errR
+	}
+	if errX == nil {
+		var stats *copyStats
+		stats, errX = copySourceToDest(copyCmd, copyCmd.client.FileTranfer(), sourceDirCopy, destinationDirCopy, copyCmd.CreateDest)
 		if copyCmd._logger != nil {
-			copyCmd._logger.Debugf("Source Folder: %s", sourceDir)
-			copyCmd._logger.Debugf("Destination Folder: %s", destinationDir)
-			copyCmd._logger.Debugf("Create Destination Folder: %v", createDestination)
+			copyCmd._logger.Infof("%sCopy to %s -> changed files: %v, unchanged files: %v, backup files: %v", prefix, copyCmd.mask(destinationDirCopy), stats.Changed, stats.Unchanged, stats.Backups)
 		} else {
-			color.LightYellow.Printf("Source Folder: %s\n", sourceDir)
-			color.LightYellow.Printf("Destination Folder: %s\n", destinationDir)
-			color.LightYellow.Printf("Create Destination Folder: %v\n", createDestination)
+			color.LightYellow.Printf("%sCopy to %s -> changed files: %v, unchanged files: %v, backup files: %v\n", prefix, copyCmd.mask(destinationDirCopy), stats.Changed, stats.Unchanged, stats.Backups)
 		}
-		err = copySourceToDest(copyCmd, transfer, sourceDir, destinationDir, createDestination)
-
 	}
-	copyCmd.started = false
-	copyCmd.finished = true
-	return err
+	if errX != nil && withItem {
+		return errors.New(copyCmd.mask("Item: " + listItem + ", Error Details: " + errX.Error()))
+	} else if errX != nil {
+		return errors.New(copyCmd.mask(errX.Error()))
+	}
+	return nil
 }
 
-func copySourceToDest(copyCmd *copyCommand, transfer generic.FileTransfer, src string, dest string, create bool) error {
-	fi, err := os.Stat(src)
+// Transfers the source files whose SHA-256 checksum differs from the remote one,
+// unchanged files are skipped
+func copySourceToDest(copyCmd *copyCommand, transfer generic.FileTransfer, src string, dest string, create bool) (*copyStats, error) {
+	var stats *copyStats = &copyStats{}
+	files, folders, err := listFiles(src, dest)
 	if err != nil {
-		return errors.New("Source file/folder doesn't exists...")
+		return stats, err
+	}
+	var remotes []string = make([]string, 0)
+	for _, file := range files {
+		remotes = append(remotes, file.Remote)
 	}
-	if fi.IsDir() {
-		//Folder
-		err = transfer.TransferFolderAs(src, dest, copyCmd.FilePerm)
-		if err != nil {
-			return err
+	sums := copyCmd.remoteChecksums(remotes)
+	var changed []copyFile = make([]copyFile, 0)
+	for _, file := range files {
+		sum, errSum := localChecksum(file.Local)
+		if errSum != nil {
+			return stats, errors.New("Unable to read source file " + file.Local + ", cause: " + errSum.Error())
 		}
-	} else {
-		//File
-		err = transfer.TransferFileAs(src, dest, copyCmd.FilePerm)
-		if err != nil {
-			return err
+		if sums[file.Remote] == sum {
+			stats.Unchanged++
+			copyCmd.logFile("Unchanged file: %s", file.Remote)
+		} else {
+			changed = append(changed, file)
 		}
 	}
-	return nil
+	if len(changed) > 0 || len(folders) > 0 {
+		if err = copyCmd.prepareFolders(len(folders) > 0, folders, changed, create); err != nil {
+			return stats, err
+		}
+	}
+	var stamp time.Time = time.Now()
+	for _, file := range changed {
+		if copyCmd.Backup && sums[file.Remote] != "" {
+			backupPath, errBackup := copyCmd.backupFile(file.Remote, stamp)
+			if errBackup != nil {
+				return stats, errBackup
+			}
+			stats.Backups++
+			copyCmd.logFile("Backup file: %s", backupPath)
+		}
+		if err = transfer.TransferFileAs(file.Local, file.Remote, copyCmd.FilePerm); err != nil {
+			return stats, err
+		}
+		stats.Changed++
+		copyCmd.logFile("Changed file: %s", file.Remote)
+	}
+	return stats, copyCmd.applyOwnership(dest, folders, files)
+}
+
+func (copyCmd *copyCommand) logFile(format string, remotePath string) {
+	if copyCmd._logger != nil {
+		copyCmd._logger.Debugf(format, copyCmd.mask(remotePath))
+	} else {
+		color.LightYellow.Printf(format+"\n", copyCmd.mask(remotePath))
+	}
 }
 
 func (copyCmd *copyCommand) Stop() error {
@@ -208,6 +271,17 @@ func (copyCmd *copyCommand) Clone() threads.StepRunnable {
 		DestinationDir: copyCmd.DestinationDir,
 		FilePerm:       copyCmd.FilePerm,
 		CreateDest:     copyCmd.CreateDest,
+		Template:       copyCmd.Template,
+		Backup:         copyCmd.Backup,
+		BackupVar:      copyCmd.BackupVar,
+		Owner:          copyCmd.Owner,
+		Group:          copyCmd.Group,
+		Recursive:      copyCmd.Recursive,
+		Escalation:     copyCmd.Escalation,
+		PasswordVar:    copyCmd.PasswordVar,
+		Parallel:       copyCmd.Parallel,
+		SecretVars:     copyCmd.SecretVars,
+		NoLog:          copyCmd.NoLog,
 		WithVars:       copyCmd.WithVars,
 		WithList:       copyCmd.WithList,
 		host:           copyCmd.host,
@@ -236,8 +310,12 @@ func (copyCmd *copyCommand) SetConfig(config defaults.ConfigPattern) {
 
 }
 
+func (copyCmd *copyCommand) mask(text string) string {
+	return redact.Mask(text, redact.Secrets(copyCmd.session, copyCmd.SecretVars, copyCmd.WithVars, copyCmd.NoLog))
+}
+
 func (copyCmd copyCommand) String() string {
-	return fmt.Sprintf("ServiceCommand {SourceDir: %v, DestDir: %v, CreateDest: %v, FilePerm: %s, WithVars: [%v], WithList: [%v]}", copyCmd.SourceDir, copyCmd.DestinationDir, copyCmd.FilePerm.String(), strconv.FormatBool(copyCmd.CreateDest), copyCmd.WithVars, copyCmd.WithList)
+	return copyCmd.mask(fmt.Sprintf("ServiceCommand {SourceDir: %v, DestDir: %v, CreateDest: %v, FilePerm: %s, Template: %v, Backup: %v, BackupVar: %v, Owner: %v, Group: %v, Recursive: %v, Escalation: %v, PasswordVar: %v, Parallel: %v, SecretVars: [%v], NoLog: %v, WithVars: [%v], WithList: [%v]}", copyCmd.SourceDir, copyCmd.DestinationDir, copyCmd.FilePerm.String(), strconv.FormatBool(copyCmd.CreateDest), copyCmd.Template, copyCmd.Backup, copyCmd.BackupVar, copyCmd.Owner, copyCmd.Group, copyCmd.Recursive, copyCmd.Escalation, copyCmd.PasswordVar, copyCmd.Parallel, copyCmd.SecretVars, copyCmd.NoLog, copyCmd.WithVars, copyCmd.WithList))
 }
 
 func (copyCmd *copyCommand) Convert(cmdValues interface{}) (threads.StepRunnable, error) {
@@ -256,7 +334,17 @@ func (copyCmd *copyCommand) Convert(cmdValues interface{}) (threads.StepRunnable
 	var withVars []string = make([]string, 0)
 	var withList []string = make([]string, 0)
 	var createDest bool = false
+	var isTemplate bool = false
+	var backup bool = false
+	var backupVar string = ""
+	var owner, group string
+	var recursive bool = false
+	var escalation string = "sudo"
+	var passwordVar string = ""
 	var filePerm os.FileMode = 0664
+	var parallel int = 1
+	var secretVars []string = make([]string, 0)
+	var noLog bool = false
 	var valType string = fmt.Sprintf("%T", cmdValues)
 	if len(valType) > 3 && "map" == valType[0:3] {
 		for key, value := range cmdValues.(map[string]interface{}) {
@@ -299,6 +387,107 @@ func (copyCmd *copyCommand) Convert(cmdValues interface{}) (threads.StepRunnable
 				} else {
 					return nil, errors.New("Unable to parse command: copy.createIfMissing, with aguments of type " + elemValType + ", expected type bool or string")
 				}
+			} else if strings.ToLower(key) == "template" {
+				if elemValType == "string" {
+					bl, err := strconv.ParseBool(fmt.Sprintf("%v", value))
+					if err != nil {
+						return nil, errors.New("Error parsing command: copy.template, cause: " + err.Error())
+					}
+					isTemplate = bl
+				} else if elemValType == "bool" {
+					isTemplate = value.(bool)
+				} else {
+					return nil, errors.New("Unable to parse command: copy.template, with aguments of type " + elemValType + ", expected type bool or string")
+				}
+			} else if strings.ToLower(key) == "backup" {
+				if elemValType == "string" {
+					bl, err := strconv.ParseBool(fmt.Sprintf("%v", value))
+					if err != nil {
+						return nil, errors.New("Error parsing command: copy.backup, cause: " + err.Error())
+					}
+					backup = bl
+				} else if elemValType == "bool" {
+					backup = value.(bool)
+				} else {
+					return nil, errors.New("Unable to parse command: copy.backup, with aguments of type " + elemValType + ", expected type bool or string")
+				}
+			} else if strings.ToLower(key) == "backupvar" {
+				if elemValType == "string" {
+					backupVar = fmt.Sprintf("%v", value)
+				} else {
+					return nil, errors.New("Unable to parse command: copy.backupVar, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "owner" || strings.ToLower(key) == "group" {
+				if elemValType == "string" || elemValType == "int" {
+					id := fmt.Sprintf("%v", value)
+					if !isValidOwner(id) {
+						return nil, errors.New("Error parsing command: copy." + key + ", invalid user/group name or id: " + id)
+					}
+					if strings.ToLower(key) == "owner" {
+						owner = id
+					} else {
+						group = id
+					}
+				} else {
+					return nil, errors.New("Unable to parse command: copy." + key + ", with aguments of type " + elemValType + ", expected type string or int")
+				}
+			} else if strings.ToLower(key) == "recursive" {
+				if elemValType == "string" {
+					bl, err := strconv.ParseBool(fmt.Sprintf("%v", value))
+					if err != nil {
+						return nil, errors.New("Error parsing command: copy.recursive, cause: " + err.Error())
+					}
+					recursive = bl
+				} else if elemValType == "bool" {
+					recursive = value.(bool)
+				} else {
+					return nil, errors.New("Unable to parse command: copy.recursive, with aguments of type " + elemValType + ", expected type bool or string")
+				}
+			} else if strings.ToLower(key) == "escalation" {
+				if elemValType == "string" {
+					escalation = strings.ToLower(fmt.Sprintf("%v", value))
+					if !privilege.IsValidMethod(escalation) {
+						return nil, errors.New("Unable to parse command: copy.escalation, with value " + escalation + ", expected one of: " + strings.Join(privilege.METHODS, ", "))
+					}
+				} else {
+					return nil, errors.New("Unable to parse command: copy.escalation, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "passwordvar" {
+				if elemValType == "string" {
+					passwordVar = fmt.Sprintf("%v", value)
+				} else {
+					return nil, errors.New("Unable to parse command: copy.passwordVar, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "parallel" {
+				count, err := strconv.Atoi(fmt.Sprintf("%v", value))
+				if err != nil || count < 1 {
+					return nil, errors.New("Unable to parse command: copy.parallel, with aguments of type " + elemValType + ", expected a positive int")
+				}
+				parallel = count
+			} else if strings.ToLower(key) == "secretvars" {
+				if elemValType == "[]string" {
+					for _, val := range value.([]string) {
+						secretVars = append(secretVars, val)
+					}
+				} else if elemValType == "[]interface {}" {
+					for _, val := range value.([]interface{}) {
+						secretVars = append(secretVars, fmt.Sprintf("%v", val))
+					}
+				} else {
+					return nil, errors.New("Unable to parse command: copy.secretVars, with aguments of type " + elemValType + ", expected type []string")
+				}
+			} else if strings.ToLower(key) == "nolog" {
+				if elemValType == "string" {
+					bl, err := strconv.ParseBool(fmt.Sprintf("%v", value))
+					if err != nil {
+						return nil, errors.New("Error parsing command: copy.noLog, cause: " + err.Error())
+					}
+					noLog = bl
+				} else if elemValType == "bool" {
+					noLog = value.(bool)
+				} else {
+					return nil, errors.New("Unable to parse command: copy.noLog, with aguments of type " + elemValType + ", expected type bool or string")
+				}
 			} else if strings.ToLower(key) == "withvars" {
 				if elemValType == "[]string" {
 					for _, val := range value.([]string) {
@@ -338,6 +527,17 @@ func (copyCmd *copyCommand) Convert(cmdValues interface{}) (threads.StepRunnable
 		DestinationDir: destDir,
 		FilePerm:       filePerm,
 		CreateDest:     createDest,
+		Template:       isTemplate,
+		Backup:         backup,
+		BackupVar:      backupVar,
+		Owner:          owner,
+		Group:          group,
+		Recursive:      recursive,
+		Escalation:     escalation,
+		PasswordVar:    passwordVar,
+		Parallel:       parallel,
+		SecretVars:     secretVars,
+		NoLog:          noLog,
 		WithVars:       withVars,
 		WithList:       withList,
 		host:           defaults.HostValue{},
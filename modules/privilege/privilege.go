@@ -0,0 +1,93 @@
+package privilege
+
+import (
+	"errors"
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"github.com/hellgate75/go-deploy/net/generic"
+	"github.com/hellgate75/go-deploy/types/module"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// Accepted values for the escalation key, su is not offered as it reads the password
+// from a terminal only and so could escalate only when connected as root
+var METHODS []string = []string{"sudo"}
+
+func IsValidMethod(method string) bool {
+	for _, allowed := range METHODS {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Reads the escalation password from the session, empty when not configured
+func Password(session module.Session, passwordVar string) (string, error) {
+	if passwordVar == "" || session == nil {
+		return "", nil
+	}
+	password, err := session.GetVar(passwordVar)
+	if err != nil {
+		return "", errors.New("Unable to read escalation password from session variable: " + passwordVar)
+	}
+	return password, nil
+}
+
+// Creates a remote folder accessible only by the connecting user
+func MakePrivateDir(client generic.NetworkClient, dir string) error {
+	output, err := client.Script("umask 077 && mkdir -p " + common.ShellQuote(dir) + " && chmod 700 " + common.ShellQuote(dir)).ExecuteWithFullOutput()
+	if err != nil {
+		return errors.New("Unable to create remote private folder " + dir + ", cause: " + err.Error() + ", output: " + strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Uploads a helper printing the sudo password into a remote folder private to the
+// connecting user, so the password never appears on a remote command line. The
+// returned path is used with Wrap and must be released with RemoveAskPass
+func UploadAskPass(client generic.NetworkClient, id string, password string) (string, error) {
+	dir := "/tmp/.go-deploy-" + id + ".d"
+	if err := MakePrivateDir(client, dir); err != nil {
+		return "", err
+	}
+	file, err := ioutil.TempFile("", "go-deploy-askpass-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("#!/bin/sh\nprintf '%s\\n' " + common.ShellQuote(password) + "\n")
+	file.Close()
+	if err != nil {
+		return "", err
+	}
+	askPass := path.Join(dir, "askpass")
+	if err = client.FileTranfer().TransferFileAs(file.Name(), askPass, 0700); err != nil {
+		RemoveAskPass(client, askPass)
+		return "", errors.New("Unable to upload escalation helper, cause: " + err.Error())
+	}
+	return askPass, nil
+}
+
+// Removes the password helper and its private folder
+func RemoveAskPass(client generic.NetworkClient, askPass string) error {
+	if askPass == "" {
+		return nil
+	}
+	_, err := client.Script("rm -rf " + common.ShellQuote(path.Dir(askPass))).ExecuteWithFullOutput()
+	return err
+}
+
+// Wraps the command in the privilege escalation to the given user, sudo reads the
+// password from the askPass helper when given and never prompts otherwise
+func Wrap(command string, user string, askPass string) string {
+	if user == "" {
+		return command
+	}
+	if askPass != "" {
+		return "SUDO_ASKPASS=" + common.ShellQuote(askPass) + " sudo -A -u " + common.ShellQuote(user) + " sh -c " + common.ShellQuote(command)
+	}
+	return "sudo -n -u " + common.ShellQuote(user) + " sh -c " + common.ShellQuote(command)
+}
@@ -0,0 +1,50 @@
+package privilege
+
+import (
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	var cases []struct {
+		command  string
+		user     string
+		askPass  string
+		expected string
+	} = []struct {
+		command  string
+		user     string
+		askPass  string
+		expected string
+	}{
+		{"id -u", "", "", "id -u"},
+		{"id -u", "", "/tmp/.go-deploy-x.d/askpass", "id -u"},
+		{"id -u", "root", "", "sudo -n -u 'root' sh -c 'id -u'"},
+		{"id -u", "app", "/tmp/.go-deploy-x.d/askpass", "SUDO_ASKPASS='/tmp/.go-deploy-x.d/askpass' sudo -A -u 'app' sh -c 'id -u'"},
+		{"echo 'hi'", "root", "", "sudo -n -u 'root' sh -c 'echo '\\''hi'\\'''"},
+	}
+	for _, tc := range cases {
+		if value := Wrap(tc.command, tc.user, tc.askPass); value != tc.expected {
+			t.Errorf("Wrap(%q, %q, %q) = %s, expected %s", tc.command, tc.user, tc.askPass, value, tc.expected)
+		}
+	}
+}
+
+func TestIsValidMethod(t *testing.T) {
+	var cases []struct {
+		method   string
+		expected bool
+	} = []struct {
+		method   string
+		expected bool
+	}{
+		{"sudo", true},
+		{"su", false},
+		{"doas", false},
+		{"", false},
+	}
+	for _, tc := range cases {
+		if value := IsValidMethod(tc.method); value != tc.expected {
+			t.Errorf("IsValidMethod(%q) = %v, expected %v", tc.method, value, tc.expected)
+		}
+	}
+}
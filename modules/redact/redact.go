@@ -0,0 +1,53 @@
+package redact
+
+import (
+	"github.com/hellgate75/go-deploy/types/module"
+	"sort"
+	"strings"
+)
+
+// Replacement text for secret values
+const MASK string = "******"
+
+// Reads the values of the given session variables, skipping missing or empty ones
+func SessionValues(session module.Session, names []string) []string {
+	var values []string = make([]string, 0)
+	if session == nil {
+		return values
+	}
+	for _, name := range names {
+		value, err := session.GetVar(name)
+		if err == nil && value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Secret values of a step: the secretVars session variables and, when the step
+// is marked noLog, the withVars session variables too
+func Secrets(session module.Session, secretVars []string, withVars []string, noLog bool) []string {
+	var names []string = append([]string{}, secretVars...)
+	if noLog {
+		names = append(names, withVars...)
+	}
+	return SessionValues(session, names)
+}
+
+// Masks any occurrence of the secret values in the text, longest values first
+// so a secret containing another one is never partially revealed
+func Mask(text string, secrets []string) string {
+	var sorted []string = make([]string, 0)
+	for _, secret := range secrets {
+		if secret != "" {
+			sorted = append(sorted, secret)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+	for _, secret := range sorted {
+		text = strings.ReplaceAll(text, secret, MASK)
+	}
+	return text
+}
@@ -0,0 +1,31 @@
+package redact
+
+import (
+	"testing"
+)
+
+func TestMask(t *testing.T) {
+	var cases []struct {
+		text     string
+		secrets  []string
+		expected string
+	} = []struct {
+		text     string
+		secrets  []string
+		expected string
+	}{
+		{"password is s3cret", []string{"s3cret"}, "password is ******"},
+		{"token abcdef and abc", []string{"abc", "abcdef"}, "token ****** and ******"},
+		{"token abcdef and abc", []string{"abcdef", "abc"}, "token ****** and ******"},
+		{"user admin, password admin123", []string{"admin", "admin123"}, "user ******, password ******"},
+		{"nothing to hide", []string{"s3cret"}, "nothing to hide"},
+		{"empty secrets are ignored", []string{""}, "empty secrets are ignored"},
+		{"no secrets at all", nil, "no secrets at all"},
+		{"repeated x1 x1 x1", []string{"x1"}, "repeated ****** ****** ******"},
+	}
+	for _, tc := range cases {
+		if value := Mask(tc.text, tc.secrets); value != tc.expected {
+			t.Errorf("Mask(%q, %q) = %q, expected %q", tc.text, tc.secrets, value, tc.expected)
+		}
+	}
+}
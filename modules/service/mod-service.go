@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gookit/color"
+	"github.com/hellgate75/go-deploy-modules/modules/privilege"
+	"github.com/hellgate75/go-deploy-modules/modules/redact"
 	"github.com/hellgate75/go-tcp-common/log"
 	"github.com/hellgate75/go-deploy/modules/meta"
 	"github.com/hellgate75/go-deploy/net/generic"
@@ -11,6 +13,7 @@ import (
 	"github.com/hellgate75/go-deploy/types/module"
 	"github.com/hellgate75/go-deploy/types/threads"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -23,6 +26,16 @@ var ERROR_TYPE reflect.Type = reflect.TypeOf(errors.New(""))
 type serviceCommand struct {
 	Name         string
 	State        string
+	Enabled      *bool
+	Masked       *bool
+	WaitFor      *waitCondition
+	SaveState    string
+	Unit         *unitDefinition
+	AsRoot       bool
+	Escalation   string
+	PasswordVar  string
+	SecretVars   []string
+	NoLog        bool
 	WithVars     []string
 	WithList     []string
 	host         defaults.HostValue
@@ -37,6 +50,8 @@ type serviceCommand struct {
 	paused       bool
 	_running     bool
 	_logger		log.Logger
+	_remote      *rootClient
+	changed      bool
 }
 
 func (service *serviceCommand) SetLogger(l log.Logger) {
@@ -60,16 +75,178 @@ func (service *serviceCommand) Run() error {
 		service.paused = false
 		service.started = false
 	}()
-	//TODO MOD-SERVICE noy implemented yet
+	if errEsc := service.prepareEscalation(); errEsc != nil {
+		return errEsc
+	}
+	defer service.releaseEscalation()
+	backend, errBackend := detectBackend(service.remoteClient())
+	if errBackend != nil {
+		return errBackend
+	}
 	if service._logger != nil {
-		service._logger.Warnf("Service command not implemented, service data: %s", service.String())
+		service._logger.Debugf("Host labelled:  %s, init system: %s", service.host.Name, backend.Name())
+	} else {
+		color.LightYellow.Printf("Host labelled:  %s, init system: %s\n", service.host.Name, backend.Name())
+	}
+	service.changed = false
+	if service.WithList != nil && len(service.WithList) > 0 {
+		if strings.Index(service.Name, "{{ item }}") < 0 {
+			return errors.New("Service name doesn't contain scalable variable '{{ item }}'")
+		}
+		// Items are all processed, failures are reported together at the end
+		var failures []string = make([]string, 0)
+		for index, listItem := range service.WithList {
+			saveAs := ""
+			if service.SaveState != "" {
+				saveAs = service.SaveState + ".items[" + strconv.Itoa(index) + "]"
+			}
+			changed, errX := service.runService(backend, listItem, saveAs)
+			service.changed = service.changed || changed
+			if errX != nil {
+				failures = append(failures, "Service item: "+listItem+", Error Details: "+errX.Error())
+				if saveAs != "" {
+					service.saveVar(saveAs+".failed", "true")
+				}
+			}
+		}
+		if len(failures) > 0 {
+			err = errors.New(strings.Join(failures, "\n"))
+		}
+		if service.SaveState != "" {
+			service.saveVar(service.SaveState+".changed", strconv.FormatBool(service.changed))
+			service.saveVar(service.SaveState+".items.length", strconv.Itoa(len(service.WithList)))
+		}
 	} else {
-		color.LightYellow.Printf("Service command not implemented, service data: %s\n", service.String())
+		service.changed, err = service.runService(backend, "", service.SaveState)
 	}
 	service.started = false
 	service.finished = true
+	if err != nil {
+		return errors.New(service.mask(err.Error()))
+	}
 	return err
 }
+
+// Drives a single service, resolving the list item and the session variables in the name,
+// facts are saved under the saveAs prefix when not empty
+func (service *serviceCommand) runService(backend initBackend, item string, saveAs string) (bool, error) {
+	var changed bool = false
+	var err error
+	name := service.substituteVars(strings.ReplaceAll(service.Name, "{{ item }}", item))
+	if service._logger != nil {
+		service._logger.Debugf("Executing service: %s, state: %s", service.mask(name), service.State)
+	} else {
+		color.LightYellow.Printf("Executing service: %s, state: %s\n", service.mask(name), service.State)
+	}
+	var wasActive bool = false
+	if saveAs != "" {
+		wasActive, _ = backend.IsActive(name)
+	}
+	if service.Unit != nil {
+		changed, err = service.installUnit(backend, name, item)
+		if err != nil {
+			return changed, err
+		}
+	}
+	var logOffset int64
+	if service.WaitFor != nil {
+		logOffset = service.WaitFor.logOffset(service.remoteClient())
+	}
+	done, err := service.applyAll(backend, name)
+	changed = changed || done
+	if err == nil && service.WaitFor != nil && (service.State == "started" || service.State == "restarted" || service.State == "reloaded") {
+		if service._logger != nil {
+			service._logger.Debugf("Waiting for service %s: %s", service.mask(name), service.mask(service.WaitFor.String()))
+		} else {
+			color.LightYellow.Printf("Waiting for service %s: %s\n", service.mask(name), service.mask(service.WaitFor.String()))
+		}
+		err = service.WaitFor.wait(service.remoteClient(), backend, name, logOffset)
+	}
+	if err != nil {
+		return changed, err
+	}
+	if service._logger != nil {
+		service._logger.Infof("Service %s state %s -> changed: %v", service.mask(name), service.State, changed)
+	} else {
+		color.LightYellow.Printf("Service %s state %s -> changed: %v\n", service.mask(name), service.State, changed)
+	}
+	if saveAs != "" {
+		err = service.saveFacts(backend, name, saveAs, wasActive, changed)
+	}
+	return changed, err
+}
+
+func (service *serviceCommand) saveFacts(backend initBackend, name string, saveAs string, wasActive bool, changed bool) error {
+	facts, err := backend.Facts(name)
+	if err != nil {
+		return err
+	}
+	vars := facts.toVars()
+	vars["name"] = name
+	vars["failed"] = "false"
+	vars["wasActive"] = strconv.FormatBool(wasActive)
+	vars["changed"] = strconv.FormatBool(changed)
+	vars["initSystem"] = backend.Name()
+	for key, value := range vars {
+		service.saveVar(saveAs+"."+key, value)
+	}
+	return nil
+}
+
+func (service *serviceCommand) saveVar(varName string, value string) {
+	done := service.session.SetVar(varName, value)
+	if !done {
+		if service._logger != nil {
+			service._logger.Warnf("Unable to save state: %s", varName)
+		} else {
+			color.LightYellow.Printf("Unable to save state: %s\n", varName)
+		}
+	}
+}
+
+// Replaces the '{{ var }}' placeholders with the session variables listed in WithVars
+func (service *serviceCommand) substituteVars(text string) string {
+	if service.WithVars != nil && len(service.WithVars) > 0 {
+		for _, varKey := range service.WithVars {
+			varValue, varValueErr := service.session.GetVar(varKey)
+			if varValueErr == nil {
+				text = strings.ReplaceAll(text, "{{ "+varKey+" }}", varValue)
+			}
+		}
+	}
+	return text
+}
+
+func (service *serviceCommand) applyAll(backend initBackend, name string) (bool, error) {
+	var changed bool = false
+	// Unmask must precede any other action, while masking follows them
+	if service.Masked != nil && !*service.Masked {
+		done, err := applyMasking(backend, name, service.Masked)
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || done
+	}
+	done, err := applyState(backend, name, service.State)
+	if err != nil {
+		return changed, err
+	}
+	changed = changed || done
+	done, err = applyEnablement(backend, name, service.Enabled)
+	if err != nil {
+		return changed, err
+	}
+	changed = changed || done
+	if service.Masked != nil && *service.Masked {
+		done, err = applyMasking(backend, name, service.Masked)
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || done
+	}
+	return changed, nil
+}
+
 func (service *serviceCommand) Stop() error {
 	service._running = false
 	return nil
@@ -119,6 +296,16 @@ func (service *serviceCommand) Clone() threads.StepRunnable {
 	return &serviceCommand{
 		Name:         service.Name,
 		State:        service.State,
+		Enabled:      service.Enabled,
+		Masked:       service.Masked,
+		WaitFor:      service.WaitFor,
+		SaveState:    service.SaveState,
+		Unit:         service.Unit,
+		AsRoot:       service.AsRoot,
+		Escalation:   service.Escalation,
+		PasswordVar:  service.PasswordVar,
+		SecretVars:   service.SecretVars,
+		NoLog:        service.NoLog,
 		WithVars:     service.WithVars,
 		WithList:     service.WithList,
 		host:         service.host,
@@ -145,8 +332,33 @@ func (service *serviceCommand) SetConfig(config defaults.ConfigPattern) {
 	service.config = config
 }
 
+func (service *serviceCommand) mask(text string) string {
+	return redact.Mask(text, redact.Secrets(service.session, service.SecretVars, service.WithVars, service.NoLog))
+}
+
 func (service serviceCommand) String() string {
-	return fmt.Sprintf("serviceCommand {Name: %v, State: %v, WithVars: [%v], WithList: [%v]}", service.Name, service.State, service.WithVars, service.WithList)
+	return service.mask(fmt.Sprintf("serviceCommand {Name: %v, State: %v, Enabled: %v, Masked: %v, WaitFor: %v, SaveState: %v, Unit: %v, AsRoot: %v, Escalation: %v, PasswordVar: %v, SecretVars: [%v], NoLog: %v, WithVars: [%v], WithList: [%v]}", service.Name, service.State, boolPtrString(service.Enabled), boolPtrString(service.Masked), service.WaitFor, service.SaveState, service.Unit, strconv.FormatBool(service.AsRoot), service.Escalation, service.PasswordVar, service.SecretVars, service.NoLog, service.WithVars, service.WithList))
+}
+
+func boolPtrString(value *bool) string {
+	if value == nil {
+		return "<unset>"
+	}
+	return strconv.FormatBool(*value)
+}
+
+func parseBoolValue(key string, value interface{}) (bool, error) {
+	var elemValType string = fmt.Sprintf("%T", value)
+	if elemValType == "string" {
+		bl, err := strconv.ParseBool(fmt.Sprintf("%v", value))
+		if err != nil {
+			return false, errors.New("Error parsing command: service." + key + ", cause: " + err.Error())
+		}
+		return bl, nil
+	} else if elemValType == "bool" {
+		return value.(bool), nil
+	}
+	return false, errors.New("Unable to parse command: service." + key + ", with aguments of type " + elemValType + ", expected type bool or string")
 }
 
 func (service *serviceCommand) Convert(cmdValues interface{}) (threads.StepRunnable, error) {
@@ -162,6 +374,15 @@ func (service *serviceCommand) Convert(cmdValues interface{}) (threads.StepRunna
 
 	}()
 	var name, state string
+	var enabled, masked *bool
+	var waitFor *waitCondition
+	var asVar string = ""
+	var unit *unitDefinition
+	var asRoot bool = false
+	var escalation string = "sudo"
+	var passwordVar string = ""
+	var secretVars []string = make([]string, 0)
+	var noLog bool = false
 	var withVars []string = make([]string, 0)
 	var withList []string = make([]string, 0)
 	var valType string = fmt.Sprintf("%T", cmdValues)
@@ -181,10 +402,82 @@ func (service *serviceCommand) Convert(cmdValues interface{}) (threads.StepRunna
 				}
 			} else if strings.ToLower(key) == "state" {
 				if elemValType == "string" {
-					state = fmt.Sprintf("%v", value)
+					state = strings.ToLower(fmt.Sprintf("%v", value))
+					if !isValidState(state) {
+						return nil, errors.New("Unable to parse command: service.state, with value " + state + ", expected one of: " + strings.Join(SERVICE_STATES, ", "))
+					}
 				} else {
 					return nil, errors.New("Unable to parse command: service.state, with aguments of type " + elemValType + ", expected type string")
 				}
+			} else if strings.ToLower(key) == "enabled" {
+				bl, err := parseBoolValue("enabled", value)
+				if err != nil {
+					return nil, err
+				}
+				enabled = &bl
+			} else if strings.ToLower(key) == "masked" {
+				bl, err := parseBoolValue("masked", value)
+				if err != nil {
+					return nil, err
+				}
+				masked = &bl
+			} else if strings.ToLower(key) == "waitfor" {
+				cond, err := parseWaitFor(value)
+				if err != nil {
+					return nil, err
+				}
+				waitFor = cond
+			} else if strings.ToLower(key) == "unit" {
+				def, err := parseUnit(value)
+				if err != nil {
+					return nil, err
+				}
+				unit = def
+			} else if strings.ToLower(key) == "savestate" {
+				if elemValType == "string" {
+					asVar = fmt.Sprintf("%v", value)
+				} else {
+					return nil, errors.New("Unable to parse command: service.saveState, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "secretvars" {
+				if elemValType == "[]string" {
+					for _, val := range value.([]string) {
+						secretVars = append(secretVars, val)
+					}
+				} else if elemValType == "[]interface {}" {
+					for _, val := range value.([]interface{}) {
+						secretVars = append(secretVars, fmt.Sprintf("%v", val))
+					}
+				} else {
+					return nil, errors.New("Unable to parse command: service.secretVars, with aguments of type " + elemValType + ", expected type []string")
+				}
+			} else if strings.ToLower(key) == "asroot" {
+				bl, err := parseBoolValue("asRoot", value)
+				if err != nil {
+					return nil, err
+				}
+				asRoot = bl
+			} else if strings.ToLower(key) == "escalation" {
+				if elemValType == "string" {
+					escalation = strings.ToLower(fmt.Sprintf("%v", value))
+					if !privilege.IsValidMethod(escalation) {
+						return nil, errors.New("Unable to parse command: service.escalation, with value " + escalation + ", expected one of: " + strings.Join(privilege.METHODS, ", "))
+					}
+				} else {
+					return nil, errors.New("Unable to parse command: service.escalation, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "passwordvar" {
+				if elemValType == "string" {
+					passwordVar = fmt.Sprintf("%v", value)
+				} else {
+					return nil, errors.New("Unable to parse command: service.passwordVar, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "nolog" {
+				bl, err := parseBoolValue("noLog", value)
+				if err != nil {
+					return nil, err
+				}
+				noLog = bl
 			} else if strings.ToLower(key) == "withvars" {
 				if elemValType == "[]string" {
 					for _, val := range value.([]string) {
@@ -216,12 +509,37 @@ func (service *serviceCommand) Convert(cmdValues interface{}) (threads.StepRunna
 	} else {
 		return nil, errors.New("Unable to parse command: service, with aguments of type " + valType + ", expected type map[string]interfce{}")
 	}
+	if name == "" {
+		return nil, errors.New("Missing command: service.name -> mandatory field")
+	}
+	if masked != nil && *masked && enabled != nil && *enabled {
+		return nil, errors.New("Conflicting commands: service.enabled and service.masked cannot be both true")
+	}
+	if state == "enabled" && ((enabled != nil && !*enabled) || (masked != nil && *masked)) {
+		return nil, errors.New("Conflicting commands: service.state enabled cannot be used with service.enabled false or service.masked true")
+	}
+	if state == "disabled" && enabled != nil && *enabled {
+		return nil, errors.New("Conflicting commands: service.state disabled cannot be used with service.enabled true")
+	}
+	if state == "masked" && ((masked != nil && !*masked) || (enabled != nil && *enabled)) {
+		return nil, errors.New("Conflicting commands: service.state masked cannot be used with service.masked false or service.enabled true")
+	}
 	if superError != nil {
 		return nil, superError
 	}
 	runnable := &serviceCommand{
 		Name:         name,
 		State:        state,
+		Enabled:      enabled,
+		Masked:       masked,
+		WaitFor:      waitFor,
+		SaveState:    asVar,
+		Unit:         unit,
+		AsRoot:       asRoot,
+		Escalation:   escalation,
+		PasswordVar:  passwordVar,
+		SecretVars:   secretVars,
+		NoLog:        noLog,
 		WithVars:     withVars,
 		WithList:     withList,
 		host:         defaults.HostValue{},
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvertConflicts(t *testing.T) {
+	var cases []struct {
+		values  map[string]interface{}
+		message string
+	} = []struct {
+		values  map[string]interface{}
+		message string
+	}{
+		{map[string]interface{}{"enabled": "true", "masked": "true"}, "service.enabled and service.masked cannot be both true"},
+		{map[string]interface{}{"state": "enabled", "enabled": "false"}, "service.state enabled cannot be used"},
+		{map[string]interface{}{"state": "enabled", "masked": "true"}, "service.state enabled cannot be used"},
+		{map[string]interface{}{"state": "disabled", "enabled": "true"}, "service.state disabled cannot be used"},
+		{map[string]interface{}{"state": "masked", "masked": "false"}, "service.state masked cannot be used"},
+		{map[string]interface{}{"state": "masked", "enabled": "true"}, "service.state masked cannot be used"},
+		{map[string]interface{}{"escalation": "su"}, "expected one of: sudo"},
+		{map[string]interface{}{"state": "enabled", "enabled": "true"}, ""},
+		{map[string]interface{}{"state": "masked", "masked": "true", "enabled": "false"}, ""},
+		{map[string]interface{}{"state": "started", "asRoot": "true", "passwordVar": "pw"}, ""},
+	}
+	for _, tc := range cases {
+		tc.values["name"] = "app"
+		_, err := (&serviceCommand{}).Convert(tc.values)
+		if tc.message == "" {
+			if err != nil {
+				t.Errorf("Convert(%v) unexpected error: %v", tc.values, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.message) {
+			t.Errorf("Convert(%v) error = %v, expected to contain %q", tc.values, err, tc.message)
+		}
+	}
+}
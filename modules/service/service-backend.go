@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+	"github.com/hellgate75/go-deploy/net/generic"
+	"strings"
+)
+
+/*
+* Init system backend, driving a service on the remote host
+ */
+type initBackend interface {
+	Name() string
+	Start(name string) error
+	Stop(name string) error
+	Restart(name string) error
+	Reload(name string) error
+	IsActive(name string) (bool, error)
+	Enable(name string) error
+	Disable(name string) error
+	IsEnabled(name string) (bool, error)
+	Mask(name string) error
+	Unmask(name string) error
+	IsMasked(name string) (bool, error)
+	Facts(name string) (serviceFacts, error)
+}
+
+/*
+* Init system backend factory, the probe command must succeed on
+* the remote host to elect the backend
+ */
+type backendFactory struct {
+	name   string
+	probe  string
+	create func(client generic.NetworkClient) initBackend
+}
+
+// Backends in detection order, first successful probe wins
+var backends []backendFactory = []backendFactory{
+	{
+		name:   "systemd",
+		probe:  "test -d /run/systemd/system && command -v systemctl",
+		create: newSystemdBackend,
+	},
+	{
+		name:   "openrc",
+		probe:  "command -v rc-service && command -v rc-status",
+		create: newOpenRcBackend,
+	},
+	{
+		name:   "runit",
+		probe:  "command -v sv && ( test -d /etc/service || test -d /var/service )",
+		create: newRunitBackend,
+	},
+	{
+		name:   "sysv",
+		probe:  "test -d /etc/init.d",
+		create: newSysVBackend,
+	},
+}
+
+func detectBackend(client generic.NetworkClient) (initBackend, error) {
+	if client == nil {
+		return nil, errors.New("Unable to detect init system: no network client available")
+	}
+	for _, factory := range backends {
+		if _, err := runScript(client, factory.probe); err == nil {
+			return factory.create(client), nil
+		}
+	}
+	return nil, errors.New("Unable to detect init system on remote host, supported: systemd, openrc, runit, sysv")
+}
+
+func runScript(client generic.NetworkClient, command string) (string, error) {
+	bytesArr, err := client.Script(command).ExecuteWithFullOutput()
+	output := strings.TrimSpace(string(bytesArr))
+	if err != nil {
+		if output != "" {
+			return output, errors.New("Error Details: " + err.Error() + ", Output: " + output)
+		}
+		return output, err
+	}
+	return output, nil
+}
+
+func unsupportedError(backend initBackend, action string) error {
+	return errors.New("Action " + action + " is not supported by init system: " + backend.Name())
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"github.com/hellgate75/go-deploy/net/generic"
+	"strconv"
+	"strings"
+)
+
+/*
+* Service facts, as reported by the init system
+ */
+type serviceFacts struct {
+	Exists       bool
+	ActiveState  string
+	SubState     string
+	MainPid      string
+	EnabledState string
+	UnitPath     string
+	Uptime       string
+}
+
+// Session variable suffixes and values of the facts
+func (facts serviceFacts) toVars() map[string]string {
+	return map[string]string{
+		"exists":       strconv.FormatBool(facts.Exists),
+		"activeState":  facts.ActiveState,
+		"subState":     facts.SubState,
+		"mainPid":      facts.MainPid,
+		"enabledState": facts.EnabledState,
+		"unitPath":     facts.UnitPath,
+		"uptime":       facts.Uptime,
+	}
+}
+
+// Elapsed seconds since the given process started, empty when unknown
+func processUptime(client generic.NetworkClient, pid string) string {
+	if pid == "" || pid == "0" {
+		return ""
+	}
+	output, err := runScript(client, "ps -o etimes= -p "+common.ShellQuote(pid))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// First process id of the given program name, empty when not running
+func processPid(client generic.NetworkClient, name string) string {
+	output, err := runScript(client, "pidof -s "+common.ShellQuote(name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+func activeStateString(active bool) string {
+	if active {
+		return "active"
+	}
+	return "inactive"
+}
+
+func enabledStateString(backend initBackend, name string) string {
+	enabled, err := backend.IsEnabled(name)
+	if err != nil {
+		return "unknown"
+	}
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
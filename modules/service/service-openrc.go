@@ -0,0 +1,98 @@
+package service
+
+import (
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"github.com/hellgate75/go-deploy/net/generic"
+	"strings"
+)
+
+/*
+* OpenRC init backend
+ */
+type openRcBackend struct {
+	client generic.NetworkClient
+}
+
+func newOpenRcBackend(client generic.NetworkClient) initBackend {
+	return &openRcBackend{
+		client: client,
+	}
+}
+
+func (backend *openRcBackend) Name() string {
+	return "openrc"
+}
+
+func (backend *openRcBackend) rcService(name string, action string) error {
+	_, err := runScript(backend.client, "rc-service "+common.ShellQuote(name)+" "+action)
+	return err
+}
+
+func (backend *openRcBackend) Start(name string) error {
+	return backend.rcService(name, "start")
+}
+
+func (backend *openRcBackend) Stop(name string) error {
+	return backend.rcService(name, "stop")
+}
+
+func (backend *openRcBackend) Restart(name string) error {
+	return backend.rcService(name, "restart")
+}
+
+func (backend *openRcBackend) Reload(name string) error {
+	return backend.rcService(name, "reload")
+}
+
+func (backend *openRcBackend) IsActive(name string) (bool, error) {
+	_, err := runScript(backend.client, "rc-service "+common.ShellQuote(name)+" status")
+	return err == nil, nil
+}
+
+func (backend *openRcBackend) Enable(name string) error {
+	_, err := runScript(backend.client, "rc-update add "+common.ShellQuote(name)+" default")
+	return err
+}
+
+func (backend *openRcBackend) Disable(name string) error {
+	_, err := runScript(backend.client, "rc-update del "+common.ShellQuote(name)+" default")
+	return err
+}
+
+func (backend *openRcBackend) IsEnabled(name string) (bool, error) {
+	_, err := runScript(backend.client, "rc-update show default | awk '{print $1}' | grep -qx "+common.ShellQuote(name))
+	return err == nil, nil
+}
+
+func (backend *openRcBackend) Mask(name string) error {
+	return unsupportedError(backend, "mask")
+}
+
+func (backend *openRcBackend) Unmask(name string) error {
+	return unsupportedError(backend, "unmask")
+}
+
+func (backend *openRcBackend) IsMasked(name string) (bool, error) {
+	return false, nil
+}
+
+func (backend *openRcBackend) Facts(name string) (serviceFacts, error) {
+	var facts serviceFacts = serviceFacts{
+		UnitPath: "/etc/init.d/" + name,
+	}
+	_, err := runScript(backend.client, "test -x "+common.ShellQuote(facts.UnitPath))
+	facts.Exists = err == nil
+	if !facts.Exists {
+		return facts, nil
+	}
+	output, _ := runScript(backend.client, "rc-service "+common.ShellQuote(name)+" status 2>&1 || true")
+	if idx := strings.Index(output, "status:"); idx >= 0 {
+		facts.SubState = strings.TrimSpace(output[idx+len("status:"):])
+	}
+	active, _ := backend.IsActive(name)
+	facts.ActiveState = activeStateString(active)
+	facts.EnabledState = enabledStateString(backend, name)
+	facts.MainPid = processPid(backend.client, name)
+	facts.Uptime = processUptime(backend.client, facts.MainPid)
+	return facts, nil
+}
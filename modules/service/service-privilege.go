@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gookit/color"
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"github.com/hellgate75/go-deploy-modules/modules/privilege"
+	"github.com/hellgate75/go-deploy/net/generic"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+/*
+* Network client running every remote command escalated to root, the file
+* uploads are staged in a folder private to the connecting user and then
+* installed by root
+ */
+type rootClient struct {
+	generic.NetworkClient
+	askPass string
+	id      string
+	uploads int
+}
+
+func (root *rootClient) Script(command string) generic.RemoteScript {
+	return root.NetworkClient.Script(privilege.Wrap(command, "root", root.askPass))
+}
+
+func (root *rootClient) FileTranfer() generic.FileTransfer {
+	return &rootTransfer{FileTransfer: root.NetworkClient.FileTranfer(), root: root}
+}
+
+/*
+* File transfer installing the uploaded files as root
+ */
+type rootTransfer struct {
+	generic.FileTransfer
+	root *rootClient
+}
+
+func (transfer *rootTransfer) TransferFileAs(localPath string, remotePath string, perm os.FileMode) error {
+	transfer.root.uploads++
+	dir := "/tmp/.go-deploy-" + transfer.root.id + "-" + strconv.Itoa(transfer.root.uploads) + ".d"
+	if err := privilege.MakePrivateDir(transfer.root.NetworkClient, dir); err != nil {
+		return err
+	}
+	defer transfer.root.NetworkClient.Script("rm -rf " + common.ShellQuote(dir)).ExecuteWithFullOutput()
+	staged := path.Join(dir, path.Base(remotePath))
+	if err := transfer.FileTransfer.TransferFileAs(localPath, staged, 0600); err != nil {
+		return err
+	}
+	output, err := transfer.root.Script("install -m " + fmt.Sprintf("%o", perm.Perm()) + " " + common.ShellQuote(staged) + " " + common.ShellQuote(remotePath)).ExecuteWithFullOutput()
+	if err != nil {
+		return errors.New("Unable to install file " + remotePath + ", cause: " + err.Error() + ", output: " + strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Client used for the init system commands, escalated to root when required
+func (service *serviceCommand) remoteClient() generic.NetworkClient {
+	if service._remote != nil {
+		return service._remote
+	}
+	return service.client
+}
+
+// Prepares the root client for the whole step run, uploading the sudo password
+// helper when a password is required
+func (service *serviceCommand) prepareEscalation() error {
+	if !service.AsRoot {
+		return nil
+	}
+	password, err := privilege.Password(service.session, service.PasswordVar)
+	if err != nil {
+		return err
+	}
+	var askPass string
+	if password != "" {
+		askPass, err = privilege.UploadAskPass(service.client, service.uuid, password)
+		if err != nil {
+			return err
+		}
+	}
+	service._remote = &rootClient{NetworkClient: service.client, askPass: askPass, id: service.uuid}
+	return nil
+}
+
+func (service *serviceCommand) releaseEscalation() {
+	if service._remote == nil {
+		return
+	}
+	askPass := service._remote.askPass
+	service._remote = nil
+	if err := privilege.RemoveAskPass(service.client, askPass); err != nil {
+		if service._logger != nil {
+			service._logger.Warnf("Unable to remove escalation helper: %s", askPass)
+		} else {
+			color.LightYellow.Printf("Unable to remove escalation helper: %s\n", askPass)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"github.com/hellgate75/go-deploy/net/generic"
+	"strings"
+)
+
+/*
+* Runit init backend
+ */
+type runitBackend struct {
+	client generic.NetworkClient
+}
+
+func newRunitBackend(client generic.NetworkClient) initBackend {
+	return &runitBackend{
+		client: client,
+	}
+}
+
+func (backend *runitBackend) Name() string {
+	return "runit"
+}
+
+func (backend *runitBackend) sv(action string, name string) error {
+	_, err := runScript(backend.client, "sv "+action+" "+common.ShellQuote(name))
+	return err
+}
+
+func (backend *runitBackend) Start(name string) error {
+	return backend.sv("up", name)
+}
+
+func (backend *runitBackend) Stop(name string) error {
+	return backend.sv("down", name)
+}
+
+func (backend *runitBackend) Restart(name string) error {
+	return backend.sv("restart", name)
+}
+
+func (backend *runitBackend) Reload(name string) error {
+	return backend.sv("reload", name)
+}
+
+func (backend *runitBackend) IsActive(name string) (bool, error) {
+	output, err := runScript(backend.client, "sv status "+common.ShellQuote(name))
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(output, "run:"), nil
+}
+
+// Runit enables a service linking its definition into the supervised folder
+const runitServiceDir string = "$(test -d /etc/service && echo /etc/service || echo /var/service)"
+
+func (backend *runitBackend) Enable(name string) error {
+	_, err := runScript(backend.client, "ln -s /etc/sv/"+common.ShellQuote(name)+" "+runitServiceDir+"/"+common.ShellQuote(name))
+	return err
+}
+
+func (backend *runitBackend) Disable(name string) error {
+	_, err := runScript(backend.client, "rm -f "+runitServiceDir+"/"+common.ShellQuote(name))
+	return err
+}
+
+func (backend *runitBackend) IsEnabled(name string) (bool, error) {
+	_, err := runScript(backend.client, "test -e "+runitServiceDir+"/"+common.ShellQuote(name))
+	return err == nil, nil
+}
+
+func (backend *runitBackend) Mask(name string) error {
+	return unsupportedError(backend, "mask")
+}
+
+func (backend *runitBackend) Unmask(name string) error {
+	return unsupportedError(backend, "unmask")
+}
+
+func (backend *runitBackend) IsMasked(name string) (bool, error) {
+	return false, nil
+}
+
+func (backend *runitBackend) Facts(name string) (serviceFacts, error) {
+	var facts serviceFacts = serviceFacts{
+		UnitPath: "/etc/sv/" + name,
+	}
+	_, err := runScript(backend.client, "test -d "+common.ShellQuote(facts.UnitPath))
+	facts.Exists = err == nil
+	if !facts.Exists {
+		return facts, nil
+	}
+	// Sample output: run: name: (pid 123) 45s; run: log: (pid 120) 45s
+	output, _ := runScript(backend.client, "sv status "+common.ShellQuote(name)+" || true")
+	status := strings.Split(output, ";")[0]
+	if idx := strings.Index(status, ":"); idx > 0 {
+		facts.SubState = status[:idx]
+	}
+	if facts.SubState == "run" {
+		facts.ActiveState = "active"
+	} else {
+		facts.ActiveState = "inactive"
+	}
+	if idx := strings.Index(status, "(pid "); idx >= 0 {
+		rest := status[idx+len("(pid "):]
+		if end := strings.Index(rest, ")"); end > 0 {
+			facts.MainPid = rest[:end]
+			facts.Uptime = strings.TrimSuffix(strings.TrimSpace(rest[end+1:]), "s")
+		}
+	}
+	facts.EnabledState = enabledStateString(backend, name)
+	return facts, nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"strings"
+)
+
+// Accepted values for service.state
+var SERVICE_STATES []string = []string{"started", "stopped", "restarted", "reloaded", "enabled", "disabled", "masked"}
+
+func isValidState(state string) bool {
+	for _, allowed := range SERVICE_STATES {
+		if allowed == state {
+			return true
+		}
+	}
+	return false
+}
+
+// Drives the service to the desired state, acting only when the host differs,
+// it returns true when the service has been changed
+func applyState(backend initBackend, name string, state string) (bool, error) {
+	switch state {
+	case "":
+		return false, nil
+	case "started":
+		active, err := backend.IsActive(name)
+		if err != nil || active {
+			return false, err
+		}
+		return true, backend.Start(name)
+	case "stopped":
+		active, err := backend.IsActive(name)
+		if err != nil || !active {
+			return false, err
+		}
+		return true, backend.Stop(name)
+	case "restarted":
+		return true, backend.Restart(name)
+	case "reloaded":
+		active, err := backend.IsActive(name)
+		if err != nil {
+			return false, err
+		}
+		if !active {
+			return true, backend.Start(name)
+		}
+		return true, backend.Reload(name)
+	case "enabled":
+		enabled, err := backend.IsEnabled(name)
+		if err != nil || enabled {
+			return false, err
+		}
+		return true, backend.Enable(name)
+	case "disabled":
+		enabled, err := backend.IsEnabled(name)
+		if err != nil || !enabled {
+			return false, err
+		}
+		return true, backend.Disable(name)
+	case "masked":
+		masked, err := backend.IsMasked(name)
+		if err != nil || masked {
+			return false, err
+		}
+		return true, backend.Mask(name)
+	}
+	return false, errors.New("Unknown service state: " + state + ", for service: " + name + ", expected one of: " + strings.Join(SERVICE_STATES, ", "))
+}
+
+// Drives the boot-time enablement of the service, a nil value leaves it untouched,
+// it returns true when the service has been changed
+func applyEnablement(backend initBackend, name string, enabled *bool) (bool, error) {
+	if enabled == nil {
+		return false, nil
+	}
+	current, err := backend.IsEnabled(name)
+	if err != nil || current == *enabled {
+		return false, err
+	}
+	if *enabled {
+		return true, backend.Enable(name)
+	}
+	return true, backend.Disable(name)
+}
+
+// Drives the masking of the service, a nil value leaves it untouched,
+// it returns true when the service has been changed
+func applyMasking(backend initBackend, name string, masked *bool) (bool, error) {
+	if masked == nil {
+		return false, nil
+	}
+	current, err := backend.IsMasked(name)
+	if err != nil || current == *masked {
+		return false, err
+	}
+	if *masked {
+		return true, backend.Mask(name)
+	}
+	return true, backend.Unmask(name)
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+/*
+* Init system backend keeping the service status in memory
+ */
+type fakeBackend struct {
+	active  bool
+	enabled bool
+	masked  bool
+	calls   []string
+}
+
+func (backend *fakeBackend) Name() string {
+	return "fake"
+}
+func (backend *fakeBackend) Start(name string) error {
+	backend.calls = append(backend.calls, "start")
+	backend.active = true
+	return nil
+}
+func (backend *fakeBackend) Stop(name string) error {
+	backend.calls = append(backend.calls, "stop")
+	backend.active = false
+	return nil
+}
+func (backend *fakeBackend) Restart(name string) error {
+	backend.calls = append(backend.calls, "restart")
+	backend.active = true
+	return nil
+}
+func (backend *fakeBackend) Reload(name string) error {
+	backend.calls = append(backend.calls, "reload")
+	return nil
+}
+func (backend *fakeBackend) IsActive(name string) (bool, error) {
+	return backend.active, nil
+}
+func (backend *fakeBackend) Enable(name string) error {
+	backend.calls = append(backend.calls, "enable")
+	backend.enabled = true
+	return nil
+}
+func (backend *fakeBackend) Disable(name string) error {
+	backend.calls = append(backend.calls, "disable")
+	backend.enabled = false
+	return nil
+}
+func (backend *fakeBackend) IsEnabled(name string) (bool, error) {
+	return backend.enabled, nil
+}
+func (backend *fakeBackend) Mask(name string) error {
+	backend.calls = append(backend.calls, "mask")
+	backend.masked = true
+	return nil
+}
+func (backend *fakeBackend) Unmask(name string) error {
+	backend.calls = append(backend.calls, "unmask")
+	backend.masked = false
+	return nil
+}
+func (backend *fakeBackend) IsMasked(name string) (bool, error) {
+	return backend.masked, nil
+}
+func (backend *fakeBackend) Facts(name string) (serviceFacts, error) {
+	return serviceFacts{}, nil
+}
+
+func TestApplyState(t *testing.T) {
+	var cases []struct {
+		state   string
+		active  bool
+		enabled bool
+		masked  bool
+		first   []string
+		second  []string
+	} = []struct {
+		state   string
+		active  bool
+		enabled bool
+		masked  bool
+		first   []string
+		second  []string
+	}{
+		{"started", false, false, false, []string{"start"}, nil},
+		{"started", true, false, false, nil, nil},
+		{"stopped", true, false, false, []string{"stop"}, nil},
+		{"stopped", false, false, false, nil, nil},
+		{"enabled", false, false, false, []string{"enable"}, nil},
+		{"enabled", false, true, false, nil, nil},
+		{"disabled", false, true, false, []string{"disable"}, nil},
+		{"disabled", false, false, false, nil, nil},
+		{"masked", false, false, false, []string{"mask"}, nil},
+		{"masked", false, false, true, nil, nil},
+		{"", true, true, false, nil, nil},
+		// Restart and reload always act, a reload of a stopped service starts it
+		{"restarted", true, false, false, []string{"restart"}, []string{"restart"}},
+		{"reloaded", false, false, false, []string{"start"}, []string{"reload"}},
+		{"reloaded", true, false, false, []string{"reload"}, []string{"reload"}},
+	}
+	for _, tc := range cases {
+		backend := &fakeBackend{active: tc.active, enabled: tc.enabled, masked: tc.masked}
+		for round, expected := range [][]string{tc.first, tc.second} {
+			backend.calls = nil
+			changed, err := applyState(backend, "app", tc.state)
+			if err != nil {
+				t.Errorf("applyState(%q) round %d unexpected error: %v", tc.state, round+1, err)
+				break
+			}
+			if !reflect.DeepEqual(backend.calls, expected) {
+				t.Errorf("applyState(%q) round %d calls = %v, expected %v", tc.state, round+1, backend.calls, expected)
+			}
+			if changed != (len(expected) > 0) {
+				t.Errorf("applyState(%q) round %d changed = %v, expected %v", tc.state, round+1, changed, len(expected) > 0)
+			}
+		}
+	}
+}
+
+func TestApplyStateUnknown(t *testing.T) {
+	backend := &fakeBackend{}
+	if _, err := applyState(backend, "app", "paused"); err == nil {
+		t.Errorf("applyState(%q) expected error", "paused")
+	}
+	if len(backend.calls) > 0 {
+		t.Errorf("applyState(%q) calls = %v, expected none", "paused", backend.calls)
+	}
+}
+
+func TestApplyEnablementAndMasking(t *testing.T) {
+	var yes, no bool = true, false
+	var cases []struct {
+		enabled  *bool
+		masked   *bool
+		current  bool
+		expected []string
+	} = []struct {
+		enabled  *bool
+		masked   *bool
+		current  bool
+		expected []string
+	}{
+		{&yes, nil, false, []string{"enable"}},
+		{&yes, nil, true, nil},
+		{&no, nil, true, []string{"disable"}},
+		{nil, &yes, false, []string{"mask"}},
+		{nil, &no, true, []string{"unmask"}},
+		{nil, &no, false, nil},
+		{nil, nil, true, nil},
+	}
+	for _, tc := range cases {
+		backend := &fakeBackend{enabled: tc.current, masked: tc.current}
+		if _, err := applyEnablement(backend, "app", tc.enabled); err != nil {
+			t.Errorf("applyEnablement(%v) unexpected error: %v", boolPtrString(tc.enabled), err)
+		}
+		if _, err := applyMasking(backend, "app", tc.masked); err != nil {
+			t.Errorf("applyMasking(%v) unexpected error: %v", boolPtrString(tc.masked), err)
+		}
+		if !reflect.DeepEqual(backend.calls, tc.expected) {
+			t.Errorf("enabled %v, masked %v, current %v: calls = %v, expected %v", boolPtrString(tc.enabled), boolPtrString(tc.masked), tc.current, backend.calls, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"github.com/hellgate75/go-deploy/net/generic"
+	"strings"
+)
+
+/*
+* Systemd init backend
+ */
+type systemdBackend struct {
+	client generic.NetworkClient
+}
+
+func newSystemdBackend(client generic.NetworkClient) initBackend {
+	return &systemdBackend{
+		client: client,
+	}
+}
+
+func (backend *systemdBackend) Name() string {
+	return "systemd"
+}
+
+func (backend *systemdBackend) systemctl(action string, name string) error {
+	_, err := runScript(backend.client, "systemctl "+action+" "+common.ShellQuote(name))
+	return err
+}
+
+func (backend *systemdBackend) Start(name string) error {
+	return backend.systemctl("start", name)
+}
+
+func (backend *systemdBackend) Stop(name string) error {
+	return backend.systemctl("stop", name)
+}
+
+func (backend *systemdBackend) Restart(name string) error {
+	return backend.systemctl("restart", name)
+}
+
+func (backend *systemdBackend) Reload(name string) error {
+	return backend.systemctl("reload", name)
+}
+
+func (backend *systemdBackend) IsActive(name string) (bool, error) {
+	output, _ := runScript(backend.client, "systemctl is-active "+common.ShellQuote(name)+" || true")
+	return output == "active", nil
+}
+
+func (backend *systemdBackend) Enable(name string) error {
+	return backend.systemctl("enable", name)
+}
+
+func (backend *systemdBackend) Disable(name string) error {
+	return backend.systemctl("disable", name)
+}
+
+func (backend *systemdBackend) unitFileState(name string) string {
+	output, _ := runScript(backend.client, "systemctl is-enabled "+common.ShellQuote(name)+" || true")
+	return output
+}
+
+func (backend *systemdBackend) IsEnabled(name string) (bool, error) {
+	state := backend.unitFileState(name)
+	return state == "enabled" || state == "enabled-runtime" || state == "alias", nil
+}
+
+func (backend *systemdBackend) Mask(name string) error {
+	return backend.systemctl("mask", name)
+}
+
+func (backend *systemdBackend) Unmask(name string) error {
+	return backend.systemctl("unmask", name)
+}
+
+func (backend *systemdBackend) IsMasked(name string) (bool, error) {
+	return strings.HasPrefix(backend.unitFileState(name), "masked"), nil
+}
+
+func (backend *systemdBackend) Facts(name string) (serviceFacts, error) {
+	var facts serviceFacts = serviceFacts{}
+	output, err := runScript(backend.client, "systemctl show "+common.ShellQuote(name)+" --property=LoadState,ActiveState,SubState,MainPID,UnitFileState,FragmentPath")
+	if err != nil {
+		return facts, err
+	}
+	for _, line := range strings.Split(output, "\n") {
+		idx := strings.Index(line, "=")
+		if idx < 0 {
+			continue
+		}
+		value := strings.TrimSpace(line[idx+1:])
+		switch line[:idx] {
+		case "LoadState":
+			facts.Exists = value != "not-found"
+		case "ActiveState":
+			facts.ActiveState = value
+		case "SubState":
+			facts.SubState = value
+		case "MainPID":
+			facts.MainPid = value
+		case "UnitFileState":
+			facts.EnabledState = value
+		case "FragmentPath":
+			facts.UnitPath = value
+		}
+	}
+	facts.Uptime = processUptime(backend.client, facts.MainPid)
+	return facts, nil
+}
+
+func (backend *systemdBackend) InstallUnit(localPath string, remotePath string, checksum string) (bool, error) {
+	output, _ := runScript(backend.client, "sha256sum "+common.ShellQuote(remotePath)+" 2>/dev/null | cut -d ' ' -f 1")
+	if output == checksum {
+		return false, nil
+	}
+	err := backend.client.FileTranfer().TransferFileAs(localPath, remotePath, 0644)
+	if err != nil {
+		return false, err
+	}
+	_, err = runScript(backend.client, "systemctl daemon-reload")
+	return true, err
+}
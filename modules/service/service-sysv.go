@@ -0,0 +1,96 @@
+package service
+
+import (
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"github.com/hellgate75/go-deploy/net/generic"
+	"strings"
+)
+
+/*
+* SysV init scripts backend
+ */
+type sysVBackend struct {
+	client generic.NetworkClient
+}
+
+func newSysVBackend(client generic.NetworkClient) initBackend {
+	return &sysVBackend{
+		client: client,
+	}
+}
+
+func (backend *sysVBackend) Name() string {
+	return "sysv"
+}
+
+func (backend *sysVBackend) initScript(name string, action string) error {
+	_, err := runScript(backend.client, "/etc/init.d/"+common.ShellQuote(name)+" "+action)
+	return err
+}
+
+func (backend *sysVBackend) Start(name string) error {
+	return backend.initScript(name, "start")
+}
+
+func (backend *sysVBackend) Stop(name string) error {
+	return backend.initScript(name, "stop")
+}
+
+func (backend *sysVBackend) Restart(name string) error {
+	return backend.initScript(name, "restart")
+}
+
+func (backend *sysVBackend) Reload(name string) error {
+	return backend.initScript(name, "reload")
+}
+
+func (backend *sysVBackend) IsActive(name string) (bool, error) {
+	_, err := runScript(backend.client, "/etc/init.d/"+common.ShellQuote(name)+" status")
+	return err == nil, nil
+}
+
+func (backend *sysVBackend) Enable(name string) error {
+	_, err := runScript(backend.client, "if command -v update-rc.d; then update-rc.d "+common.ShellQuote(name)+" defaults && update-rc.d "+common.ShellQuote(name)+" enable; else chkconfig "+common.ShellQuote(name)+" on; fi")
+	return err
+}
+
+func (backend *sysVBackend) Disable(name string) error {
+	_, err := runScript(backend.client, "if command -v update-rc.d; then update-rc.d "+common.ShellQuote(name)+" disable; else chkconfig "+common.ShellQuote(name)+" off; fi")
+	return err
+}
+
+func (backend *sysVBackend) IsEnabled(name string) (bool, error) {
+	_, err := runScript(backend.client, "ls /etc/rc[2345].d/S[0-9][0-9]"+common.ShellQuote(name)+" || ls /etc/rc.d/rc[2345].d/S[0-9][0-9]"+common.ShellQuote(name))
+	return err == nil, nil
+}
+
+func (backend *sysVBackend) Mask(name string) error {
+	return unsupportedError(backend, "mask")
+}
+
+func (backend *sysVBackend) Unmask(name string) error {
+	return unsupportedError(backend, "unmask")
+}
+
+func (backend *sysVBackend) IsMasked(name string) (bool, error) {
+	return false, nil
+}
+
+func (backend *sysVBackend) Facts(name string) (serviceFacts, error) {
+	var facts serviceFacts = serviceFacts{
+		UnitPath: "/etc/init.d/" + name,
+	}
+	_, err := runScript(backend.client, "test -x "+common.ShellQuote(facts.UnitPath))
+	facts.Exists = err == nil
+	if !facts.Exists {
+		return facts, nil
+	}
+	output, _ := runScript(backend.client, "/etc/init.d/"+common.ShellQuote(name)+" status 2>&1 || true")
+	facts.SubState = strings.Split(output, "\n")[0]
+	active, _ := backend.IsActive(name)
+	facts.ActiveState = activeStateString(active)
+	facts.EnabledState = enabledStateString(backend, name)
+	facts.MainPid = processPid(backend.client, name)
+	facts.Uptime = processUptime(backend.client, facts.MainPid)
+	return facts, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var DEFAULT_UNIT_DIR string = "/etc/systemd/system"
+
+/*
+* Unit file definition, from a local file or inline content
+ */
+type unitDefinition struct {
+	Path    string
+	Content string
+	Name    string
+	Dir     string
+}
+
+func (unit unitDefinition) String() string {
+	return fmt.Sprintf("Unit {Path: %v, Name: %v, Dir: %v, Inline: %v}", unit.Path, unit.Name, unit.Dir, unit.Content != "")
+}
+
+/*
+* Init backends able to install unit files
+ */
+type unitInstaller interface {
+	// Installs the local unit file, reloading the init system definitions
+	// only when the remote content differs, it returns true when changed
+	InstallUnit(localPath string, remotePath string, checksum string) (bool, error)
+}
+
+func parseUnit(value interface{}) (*unitDefinition, error) {
+	values, ok := common.ToStringMap(value)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Unable to parse command: service.unit, with aguments of type %T, expected type map[string]interfce{}", value))
+	}
+	var unit *unitDefinition = &unitDefinition{
+		Dir: DEFAULT_UNIT_DIR,
+	}
+	for key, val := range values {
+		var elemValType string = fmt.Sprintf("%T", val)
+		if elemValType != "string" {
+			return nil, errors.New("Unable to parse command: service.unit." + key + ", with aguments of type " + elemValType + ", expected type string")
+		}
+		switch strings.ToLower(key) {
+		case "path":
+			unit.Path = fmt.Sprintf("%v", val)
+		case "content":
+			unit.Content = fmt.Sprintf("%v", val)
+		case "name":
+			unit.Name = fmt.Sprintf("%v", val)
+		case "dir":
+			unit.Dir = fmt.Sprintf("%v", val)
+		default:
+			return nil, errors.New("Unknown command: service.unit." + key)
+		}
+	}
+	if (unit.Path == "") == (unit.Content == "") {
+		return nil, errors.New("Missing command: service.unit requires exactly one of path or content")
+	}
+	return unit, nil
+}
+
+// Remote unit file name, defaulting to the service name
+func (unit *unitDefinition) fileName(service string) string {
+	name := unit.Name
+	if name == "" {
+		name = service
+	}
+	if strings.Index(name, ".") < 0 {
+		name += ".service"
+	}
+	return name
+}
+
+// Renders the unit content into a local temporary file, the caller must remove it
+func (unit *unitDefinition) render(substitute func(string) string) (string, string, error) {
+	var content string = unit.Content
+	if unit.Path != "" {
+		bytesArr, err := ioutil.ReadFile(unit.Path)
+		if err != nil {
+			return "", "", errors.New("Unable to read unit file " + unit.Path + ", cause: " + err.Error())
+		}
+		content = string(bytesArr)
+	}
+	content = substitute(content)
+	file, err := ioutil.TempFile("", "go-deploy-unit-*")
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+	if _, err = file.WriteString(content); err != nil {
+		os.Remove(file.Name())
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(content))
+	return file.Name(), hex.EncodeToString(sum[:]), nil
+}
+
+func (service *serviceCommand) installUnit(backend initBackend, name string, item string) (bool, error) {
+	installer, ok := backend.(unitInstaller)
+	if !ok {
+		return false, unsupportedError(backend, "unit")
+	}
+	localPath, checksum, err := service.Unit.render(func(text string) string {
+		return service.substituteVars(strings.ReplaceAll(text, "{{ item }}", item))
+	})
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(localPath)
+	remotePath := strings.TrimRight(service.Unit.Dir, "/") + "/" + service.Unit.fileName(name)
+	if service._logger != nil {
+		service._logger.Debugf("Installing unit file: %s, checksum: %s", remotePath, checksum)
+	}
+	return installer.InstallUnit(localPath, remotePath, checksum)
+}
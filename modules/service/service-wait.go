@@ -0,0 +1,172 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"github.com/hellgate75/go-deploy/net/generic"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var DEFAULT_WAIT_TIMEOUT time.Duration = 60 * time.Second
+var DEFAULT_WAIT_INTERVAL time.Duration = 2 * time.Second
+
+/*
+* Service health conditions, all the given conditions must be satisfied
+ */
+type waitCondition struct {
+	Host     string
+	Port     int
+	Url      string
+	LogFile  string
+	Pattern  string
+	Active   bool
+	Timeout  time.Duration
+	Interval time.Duration
+}
+
+func (cond waitCondition) String() string {
+	return fmt.Sprintf("WaitFor {Host: %v, Port: %v, Url: %v, LogFile: %v, Pattern: %v, Active: %v, Timeout: %v, Interval: %v}", cond.Host, cond.Port, cond.Url, cond.LogFile, cond.Pattern, cond.Active, cond.Timeout, cond.Interval)
+}
+
+func parseWaitFor(value interface{}) (*waitCondition, error) {
+	values, ok := common.ToStringMap(value)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Unable to parse command: service.waitFor, with aguments of type %T, expected type map[string]interfce{}", value))
+	}
+	var cond *waitCondition = &waitCondition{
+		Host:     "127.0.0.1",
+		Timeout:  DEFAULT_WAIT_TIMEOUT,
+		Interval: DEFAULT_WAIT_INTERVAL,
+	}
+	for key, val := range values {
+		var elemValType string = fmt.Sprintf("%T", val)
+		switch strings.ToLower(key) {
+		case "host":
+			cond.Host = fmt.Sprintf("%v", val)
+		case "port":
+			port, err := strconv.Atoi(fmt.Sprintf("%v", val))
+			if err != nil {
+				return nil, errors.New("Error parsing command: service.waitFor.port, with aguments of type " + elemValType + ", cause: " + err.Error())
+			}
+			cond.Port = port
+		case "http", "url":
+			cond.Url = fmt.Sprintf("%v", val)
+		case "logfile":
+			cond.LogFile = fmt.Sprintf("%v", val)
+		case "pattern":
+			cond.Pattern = fmt.Sprintf("%v", val)
+		case "active":
+			bl, err := parseBoolValue("waitFor.active", val)
+			if err != nil {
+				return nil, err
+			}
+			cond.Active = bl
+		case "timeout":
+			duration, err := common.ParseDurationValue("service.waitFor.timeout", val)
+			if err != nil {
+				return nil, err
+			}
+			cond.Timeout = duration
+		case "interval":
+			duration, err := common.ParseDurationValue("service.waitFor.interval", val)
+			if err != nil {
+				return nil, err
+			}
+			cond.Interval = duration
+		default:
+			return nil, errors.New("Unknown command: service.waitFor." + key)
+		}
+	}
+	if (cond.LogFile == "") != (cond.Pattern == "") {
+		return nil, errors.New("Missing command: service.waitFor.logFile and service.waitFor.pattern must be used together")
+	}
+	if cond.Port == 0 && cond.Url == "" && cond.LogFile == "" && !cond.Active {
+		return nil, errors.New("Missing command: service.waitFor requires at least one of port, http, logFile/pattern or active")
+	}
+	return cond, nil
+}
+
+// Current size of the log file, the pattern is searched only in the content written
+// after it, so the lines logged before the service action are ignored
+func (cond *waitCondition) logOffset(client generic.NetworkClient) int64 {
+	if cond.LogFile == "" {
+		return 0
+	}
+	output, err := runScript(client, "{ wc -c < "+common.ShellQuote(cond.LogFile)+"; } 2>/dev/null || echo 0")
+	if err != nil {
+		return 0
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// Network check timeout in whole seconds, never less than one second
+func checkSeconds(limit time.Duration) string {
+	seconds := int(math.Ceil(limit.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
+
+// Checks all conditions once, it returns the first unsatisfied condition reason. The
+// network checks are bounded by the given limit and the log file is searched from
+// the given offset, or from the start when the file has been truncated or rotated
+func (cond *waitCondition) check(client generic.NetworkClient, backend initBackend, name string, logOffset int64, limit time.Duration) error {
+	if cond.Active {
+		active, err := backend.IsActive(name)
+		if err != nil {
+			return err
+		}
+		if !active {
+			return errors.New("service is not active for init system " + backend.Name())
+		}
+	}
+	if cond.Port > 0 {
+		host := common.ShellQuote(cond.Host)
+		port := strconv.Itoa(cond.Port)
+		seconds := checkSeconds(limit)
+		_, err := runScript(client, "nc -z -w "+seconds+" "+host+" "+port+" 2>/dev/null || timeout "+seconds+" bash -c 'echo > /dev/tcp/'"+host+"'/"+port+"' 2>/dev/null")
+		if err != nil {
+			return errors.New("port " + cond.Host + ":" + port + " is not open")
+		}
+	}
+	if cond.Url != "" {
+		output, _ := runScript(client, "curl -s --max-time "+checkSeconds(limit)+" -o /dev/null -w '%{http_code}' "+common.ShellQuote(cond.Url)+" || true")
+		if !strings.HasPrefix(output, "2") || len(output) != 3 {
+			return errors.New("endpoint " + cond.Url + " answered with status: " + output)
+		}
+	}
+	if cond.LogFile != "" {
+		logFile := common.ShellQuote(cond.LogFile)
+		offset := strconv.FormatInt(logOffset, 10)
+		_, err := runScript(client, "{ if [ \"$(wc -c < "+logFile+")\" -lt "+offset+" ]; then cat "+logFile+"; else tail -c +"+strconv.FormatInt(logOffset+1, 10)+" "+logFile+"; fi; } 2>/dev/null | grep -E -q "+common.ShellQuote(cond.Pattern))
+		if err != nil {
+			return errors.New("pattern " + cond.Pattern + " not found in log file " + cond.LogFile)
+		}
+	}
+	return nil
+}
+
+// Polls the conditions until satisfied or the timeout expires, the log offset is
+// taken with logOffset before the service action
+func (cond *waitCondition) wait(client generic.NetworkClient, backend initBackend, name string, logOffset int64) error {
+	deadline := time.Now().Add(cond.Timeout)
+	for {
+		err := cond.check(client, backend, name, logOffset, time.Until(deadline))
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(cond.Interval).After(deadline) {
+			return errors.New("Service " + name + " not healthy after " + cond.Timeout.String() + ", last failure: " + err.Error())
+		}
+		time.Sleep(cond.Interval)
+	}
+}
@@ -1,12 +1,15 @@
 package shell
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/gookit/color"
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"github.com/hellgate75/go-deploy-modules/modules/privilege"
+	"github.com/hellgate75/go-deploy-modules/modules/redact"
 	
 	//	internal "github.com/hellgate75/go-deploy-modules/modules"
-	"bytes"
 	"github.com/hellgate75/go-tcp-common/log"
 	"github.com/hellgate75/go-deploy/modules/meta"
 	"github.com/hellgate75/go-deploy/net/generic"
@@ -14,8 +17,10 @@ import (
 	"github.com/hellgate75/go-deploy/types/module"
 	"github.com/hellgate75/go-deploy/types/threads"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -26,8 +31,35 @@ var ERROR_TYPE reflect.Type = reflect.TypeOf(errors.New(""))
  */
 type shellCommand struct {
 	Exec         string
+	Args         []string
 	RunAs        string
 	AsRoot       bool
+	Escalation   string
+	PasswordVar  string
+	Env          map[string]string
+	Chdir        string
+	Timeout      time.Duration
+	Retries      int
+	Delay        time.Duration
+	Until        *regexp.Regexp
+	UntilRc      *int
+	Creates      string
+	Removes      string
+	Unless       string
+	OnlyIf       string
+	SuccessCodes []int
+	FailedWhen   *resultExpr
+	ChangedWhen  *resultExpr
+	OutputFormat string
+	Script       string
+	Interpreter  string
+	ScriptArgs   []string
+	Stdin        string
+	StdinFile    string
+	Stream       bool
+	Parallel     int
+	SecretVars   []string
+	NoLog        bool
 	WithVars     []string
 	WithList     []string
 	SaveState    string
@@ -43,6 +75,12 @@ type shellCommand struct {
 	paused       bool
 	_running     bool
 	_logger		log.Logger
+	_lock        sync.Mutex
+	_cancel      context.CancelFunc
+	_forceKill   bool
+	_askPass     string
+	_execCount   int
+	changed      bool
 }
 
 func (shell *shellCommand) SetLogger(l log.Logger) {
@@ -66,75 +104,131 @@ func (shell *shellCommand) Run() error {
 		shell.paused = false
 		shell.started = false
 	}()
+	shell._running = true
+	shell.changed = false
+	ctx, cancel := shell.newContext()
+	defer cancel()
+	if errEsc := shell.prepareEscalation(); errEsc != nil {
+		return errEsc
+	}
+	defer shell.releaseEscalation()
 	if shell._logger != nil {
-		shell._logger.Debugf("Executing command: %s", shell.Exec)
+		shell._logger.Debugf("Executing command: %s", shell.loggableCommand())
 		shell._logger.Debugf("Host labelled:  %s", shell.host.Name)
+		shell._logger.Debugf("Working directory: %s, environment: [%s]", shell.Chdir, shell.mask(shell.envString()))
 	} else {
-		color.LightYellow.Printf("Executing command: %s\n", shell.Exec)
+		color.LightYellow.Printf("Executing command: %s\n", shell.loggableCommand())
 		color.LightYellow.Printf("Host labelled:  %s\n", shell.host.Name)
+		color.LightYellow.Printf("Working directory: %s, environment: [%s]\n", shell.Chdir, shell.mask(shell.envString()))
 	}
-	buffer := bytes.NewBuffer([]byte{})
-	var command string = shell.Exec
-	if shell.WithList != nil && len(shell.WithList) > 0 && strings.Index(command, "{{ item }}") >= 0 {
+	var results []*execResult = make([]*execResult, 0)
+	var command string = shell.commandTemplate()
+	var withList bool = shell.WithList != nil && len(shell.WithList) > 0 && (shell.Script != "" || strings.Index(command, "{{ item }}") >= 0)
+	if withList && shell.Parallel > 1 {
+		results, err = shell.runItemsParallel(ctx)
+	} else if withList {
 		for _, listItem := range shell.WithList {
-			if strings.Index(command, "{{ item }}") < 0 {
-				return errors.New("Command doesn't contain scalable variable '{{ item }}'")
+			result, errItem := shell.runItem(ctx, listItem)
+			results = append(results, itemResult(listItem, result, errItem))
+			if errItem != nil {
+				err = errItem
+				break
 			}
-			commandCopy := strings.ReplaceAll(command, "{{ item }}", listItem)
-			if shell.WithVars != nil && len(shell.WithVars) > 0 {
-				for _, varKey := range shell.WithVars {
-					varValue, varValueErr := shell.session.GetVar(varKey)
-					if varValueErr == nil {
-						commandCopy = strings.ReplaceAll(commandCopy, "{{ "+varKey+" }}", varValue)
-					}
-				}
-			}
-
-			script := shell.client.Script(commandCopy)
-			//	script.SetStdio(buffer, buffer)
-			bytesArr, errCmd := script.ExecuteWithFullOutput()
-			if errCmd != nil {
-				return errors.New("Error Details: " + errCmd.Error() + ", StdErr: " + string(bytesArr) + ", Output: " + buffer.String())
-			}
-			buffer.Write(bytesArr)
 		}
 	} else {
-		if shell.WithVars != nil && len(shell.WithVars) > 0 {
-			for _, varKey := range shell.WithVars {
-				varValue, varValueErr := shell.session.GetVar(varKey)
-				if varValueErr == nil {
-					command = strings.ReplaceAll(command, "{{ "+varKey+" }}", varValue)
-				}
-			}
+		result, errItem := shell.runItem(ctx, "")
+		if result != nil {
+			results = append(results, result)
 		}
-		script := shell.client.Script(command)
-		//	script.SetStdio(buffer, buffer)
-		bytesArr, errCmd := script.ExecuteWithFullOutput()
+		err = errItem
+	}
+	for _, result := range results {
+		shell.changed = shell.changed || result.Changed
+	}
+	if shell._logger != nil {
+		shell._logger.Debugf("Command completed -> changed: %v", shell.changed)
+	} else {
+		color.LightYellow.Printf("Command completed -> changed: %v\n", shell.changed)
+	}
+	shell.saveResults(results, withList)
+	shell.started = false
+	shell.finished = true
+	return err
+}
+// Runs the command for the given list item, unless skipped by the guards
+func (shell *shellCommand) runItem(ctx context.Context, item string) (*execResult, error) {
+	skip, errGuard := shell.checkGuards(ctx, item)
+	if errGuard != nil {
+		return nil, errGuard
+	}
+	if skip {
+		return &execResult{Item: item, Start: time.Now(), End: time.Now(), Skipped: true}, nil
+	}
+	var command string
+	if shell.Script != "" {
+		remotePath, errUpload := shell.uploadScript(item)
+		if errUpload != nil {
+			return nil, errUpload
+		}
+		defer shell.removeScript(remotePath)
+		command = shell.renderScriptCommand(remotePath, item)
+	} else {
+		command = shell.renderCommand(item)
+	}
+	command, stdinPath, errStdin := shell.attachStdin(shell.escalate(command), item)
+	if errStdin != nil {
+		return nil, errStdin
+	}
+	if stdinPath != "" {
+		defer shell.removeRemoteFile(stdinPath)
+	}
+	result, errCmd := shell.executeWithRetries(ctx, command, item)
+	result.Item = item
+	if errors.Is(errCmd, TIMEOUT_ERROR) || errors.Is(errCmd, STOPPED_ERROR) {
+		return result, errCmd
+	}
+	failed, errEval := shell.isFailed(result, errCmd)
+	if errEval != nil {
+		return result, errEval
+	}
+	if failed {
+		var details string = "Rc: " + strconv.Itoa(result.Rc) + ", StdErr: " + strings.TrimSpace(result.Stderr)
 		if errCmd != nil {
-			return errCmd
+			details = "Error Details: " + errCmd.Error() + ", " + details
+		} else {
+			details = "Failure condition met, " + details
+		}
+		if item != "" {
+			details = "Item: " + item + ", " + details
 		}
-		buffer.Write(bytesArr)
+		return result, errors.New(shell.mask(details))
 	}
-
-	if shell.SaveState != "" {
-		done := shell.session.SetVar(shell.SaveState, strings.TrimSpace(buffer.String()))
-		if ! done {
-			if shell._logger != nil {
-				shell._logger.Warnf("Unable to save state: %s", shell.SaveState)
-			} else {
-				color.LightYellow.Printf("Unable to save state: %s\n", shell.SaveState)
+	if shell.OutputFormat != "" {
+		decoded, errDecode := decodeOutput(shell.OutputFormat, result.Stdout)
+		if errDecode != nil {
+			if item != "" {
+				return result, errors.New(shell.mask("Item: " + item + ", " + errDecode.Error()))
 			}
+			return result, errors.New(shell.mask(errDecode.Error()))
 		}
+		result.Data = decoded
 	}
-	shell.started = false
-	shell.finished = true
-	return err
+	changed, errEval := shell.isChanged(result)
+	if errEval != nil {
+		return result, errEval
+	}
+	result.Changed = changed
+	return result, nil
 }
+
 func (shell *shellCommand) Stop() error {
 	shell._running = false
+	shell.cancel(false)
 	return nil
 }
 func (shell *shellCommand) Kill() error {
+	shell._running = false
+	shell.cancel(true)
 	return nil
 }
 func (shell *shellCommand) Pause() error {
@@ -179,8 +273,35 @@ func (shell *shellCommand) UpTime() time.Duration {
 func (shell *shellCommand) Clone() threads.StepRunnable {
 	return &shellCommand{
 		Exec:         shell.Exec,
+		Args:         shell.Args,
 		RunAs:        shell.RunAs,
 		AsRoot:       shell.AsRoot,
+		Escalation:   shell.Escalation,
+		PasswordVar:  shell.PasswordVar,
+		Env:          shell.Env,
+		Chdir:        shell.Chdir,
+		Timeout:      shell.Timeout,
+		Retries:      shell.Retries,
+		Delay:        shell.Delay,
+		Until:        shell.Until,
+		UntilRc:      shell.UntilRc,
+		Creates:      shell.Creates,
+		Removes:      shell.Removes,
+		Unless:       shell.Unless,
+		OnlyIf:       shell.OnlyIf,
+		SuccessCodes: shell.SuccessCodes,
+		FailedWhen:   shell.FailedWhen,
+		ChangedWhen:  shell.ChangedWhen,
+		OutputFormat: shell.OutputFormat,
+		Script:       shell.Script,
+		Interpreter:  shell.Interpreter,
+		ScriptArgs:   shell.ScriptArgs,
+		Stdin:        shell.Stdin,
+		StdinFile:    shell.StdinFile,
+		Stream:       shell.Stream,
+		Parallel:     shell.Parallel,
+		SecretVars:   shell.SecretVars,
+		NoLog:        shell.NoLog,
 		WithVars:     shell.WithVars,
 		WithList:     shell.WithList,
 		SaveState:    shell.SaveState,
@@ -208,8 +329,19 @@ func (shell *shellCommand) SetConfig(config defaults.ConfigPattern) {
 	shell.config = config
 }
 
-func (shell shellCommand) String() string {
-	return fmt.Sprintf("ShellCommand {Exec: %v, RunAs: %v, AsRoot: %v, WithVars: [%v], WithList: [%v]}", shell.Exec, shell.RunAs, strconv.FormatBool(shell.AsRoot), shell.WithVars, shell.WithList)
+func (shell *shellCommand) String() string {
+	var exec, args, script string = shell.Exec, strings.Join(shell.Args, " "), shell.Script
+	if shell.NoLog {
+		exec, args, script = redact.MASK, redact.MASK, redact.MASK
+	}
+	return shell.mask(fmt.Sprintf("ShellCommand {Exec: %v, Args: [%v], RunAs: %v, AsRoot: %v, Escalation: %v, PasswordVar: %v, Env: [%v], Chdir: %v, Timeout: %v, Retries: %v, Delay: %v, Until: %v, UntilRc: %v, Creates: %v, Removes: %v, Unless: %v, OnlyIf: %v, SuccessCodes: %v, FailedWhen: %v, ChangedWhen: %v, OutputFormat: %v, Script: %v, Interpreter: %v, ScriptArgs: [%v], Stdin: %v, StdinFile: %v, Stream: %v, Parallel: %v, SecretVars: [%v], NoLog: %v, WithVars: [%v], WithList: [%v]}", exec, args, shell.RunAs, strconv.FormatBool(shell.AsRoot), shell.Escalation, shell.PasswordVar, shell.envString(), shell.Chdir, shell.Timeout, shell.Retries, shell.Delay, shell.Until, intPtrString(shell.UntilRc), shell.Creates, shell.Removes, shell.Unless, shell.OnlyIf, shell.SuccessCodes, shell.FailedWhen, shell.ChangedWhen, shell.OutputFormat, script, shell.Interpreter, shell.ScriptArgs, strconv.Itoa(len(shell.Stdin)) + " bytes", shell.StdinFile, shell.Stream, shell.Parallel, shell.SecretVars, shell.NoLog, shell.WithVars, shell.WithList))
+}
+
+func intPtrString(value *int) string {
+	if value == nil {
+		return "<unset>"
+	}
+	return strconv.Itoa(*value)
 }
 
 func (shell *shellCommand) Convert(cmdValues interface{}) (threads.StepRunnable, error) {
@@ -226,9 +358,30 @@ func (shell *shellCommand) Convert(cmdValues interface{}) (threads.StepRunnable,
 	}()
 	var valType string = fmt.Sprintf("%T", cmdValues)
 	var exec string = ""
+	var args []string = make([]string, 0)
 	var runAs string = ""
-	//TODO As Root noy implemented yet
 	var asRoot bool = false
+	var escalation string = "sudo"
+	var passwordVar string = ""
+	var env map[string]string = make(map[string]string)
+	var chdir string = ""
+	var timeout time.Duration = 0
+	var retries int = 0
+	var delay time.Duration = DEFAULT_RETRY_DELAY
+	var until *regexp.Regexp
+	var untilRc *int
+	var guards map[string]string = make(map[string]string)
+	var successCodes []int = make([]int, 0)
+	var failedWhen, changedWhen *resultExpr
+	var outputFormat string = ""
+	var script string = ""
+	var interpreter string = DEFAULT_INTERPRETER
+	var scriptArgs []string = make([]string, 0)
+	var stdin, stdinFile string
+	var stream bool = false
+	var parallel int = 1
+	var secretVars []string = make([]string, 0)
+	var noLog bool = false
 	var withVars []string = make([]string, 0)
 	var withList []string = make([]string, 0)
 	var asVar string = ""
@@ -244,7 +397,13 @@ func (shell *shellCommand) Convert(cmdValues interface{}) (threads.StepRunnable,
 				if elemValType == "string" {
 					exec = fmt.Sprintf("%v", value)
 				} else if elemValType == "[]string" {
-					strings.Join(value.([]string), " ")
+					for _, val := range value.([]string) {
+						args = append(args, val)
+					}
+				} else if elemValType == "[]interface {}" {
+					for _, val := range value.([]interface{}) {
+						args = append(args, fmt.Sprintf("%v", val))
+					}
 				} else {
 					return nil, errors.New("Unable to parse command: shell.exec, with aguments of type " + elemValType + ", expected type string or []string")
 				}
@@ -275,6 +434,195 @@ func (shell *shellCommand) Convert(cmdValues interface{}) (threads.StepRunnable,
 				} else {
 					return nil, errors.New("Unable to parse command: shell.asRoot, with aguments of type " + elemValType + ", expected type bool or string")
 				}
+			} else if strings.ToLower(key) == "escalation" {
+				if elemValType == "string" {
+					escalation = strings.ToLower(fmt.Sprintf("%v", value))
+					if !privilege.IsValidMethod(escalation) {
+						return nil, errors.New("Unable to parse command: shell.escalation, with value " + escalation + ", expected one of: " + strings.Join(privilege.METHODS, ", "))
+					}
+				} else {
+					return nil, errors.New("Unable to parse command: shell.escalation, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "passwordvar" {
+				if elemValType == "string" {
+					passwordVar = fmt.Sprintf("%v", value)
+				} else {
+					return nil, errors.New("Unable to parse command: shell.passwordVar, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "env" {
+				envMap, err := parseEnv(value)
+				if err != nil {
+					return nil, err
+				}
+				env = envMap
+			} else if strings.ToLower(key) == "chdir" {
+				if elemValType == "string" {
+					chdir = fmt.Sprintf("%v", value)
+				} else {
+					return nil, errors.New("Unable to parse command: shell.chdir, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "timeout" {
+				duration, err := common.ParseDurationValue("shell.timeout", value)
+				if err != nil {
+					return nil, err
+				}
+				timeout = duration
+			} else if strings.ToLower(key) == "retries" {
+				count, err := strconv.Atoi(fmt.Sprintf("%v", value))
+				if err != nil || count < 0 {
+					return nil, errors.New("Unable to parse command: shell.retries, with aguments of type " + elemValType + ", expected a non negative int")
+				}
+				retries = count
+			} else if strings.ToLower(key) == "delay" {
+				duration, err := common.ParseDurationValue("shell.delay", value)
+				if err != nil {
+					return nil, err
+				}
+				delay = duration
+			} else if strings.ToLower(key) == "until" {
+				if elemValType == "string" {
+					re, err := regexp.Compile(fmt.Sprintf("%v", value))
+					if err != nil {
+						return nil, errors.New("Error parsing command: shell.until, cause: " + err.Error())
+					}
+					until = re
+				} else {
+					return nil, errors.New("Unable to parse command: shell.until, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "untilrc" {
+				code, err := strconv.Atoi(fmt.Sprintf("%v", value))
+				if err != nil {
+					return nil, errors.New("Unable to parse command: shell.untilRc, with aguments of type " + elemValType + ", expected type int")
+				}
+				untilRc = &code
+			} else if strings.ToLower(key) == "creates" || strings.ToLower(key) == "removes" || strings.ToLower(key) == "unless" || strings.ToLower(key) == "onlyif" {
+				if elemValType == "string" {
+					guards[strings.ToLower(key)] = fmt.Sprintf("%v", value)
+				} else {
+					return nil, errors.New("Unable to parse command: shell." + key + ", with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "successcodes" {
+				var codes []string = make([]string, 0)
+				if elemValType == "string" {
+					codes = strings.Split(fmt.Sprintf("%v", value), ",")
+				} else if elemValType == "[]int" {
+					for _, val := range value.([]int) {
+						codes = append(codes, strconv.Itoa(val))
+					}
+				} else if elemValType == "[]interface {}" {
+					for _, val := range value.([]interface{}) {
+						codes = append(codes, fmt.Sprintf("%v", val))
+					}
+				} else {
+					return nil, errors.New("Unable to parse command: shell.successCodes, with aguments of type " + elemValType + ", expected type []int or string")
+				}
+				for _, code := range codes {
+					rc, err := strconv.Atoi(strings.TrimSpace(code))
+					if err != nil {
+						return nil, errors.New("Error parsing command: shell.successCodes, cause: " + err.Error())
+					}
+					successCodes = append(successCodes, rc)
+				}
+			} else if strings.ToLower(key) == "failedwhen" || strings.ToLower(key) == "changedwhen" {
+				if elemValType != "string" {
+					return nil, errors.New("Unable to parse command: shell." + key + ", with aguments of type " + elemValType + ", expected type string")
+				}
+				expr, err := parseResultExpr(fmt.Sprintf("%v", value))
+				if err != nil {
+					return nil, errors.New("Error parsing command: shell." + key + ", cause: " + err.Error())
+				}
+				if strings.ToLower(key) == "failedwhen" {
+					failedWhen = expr
+				} else {
+					changedWhen = expr
+				}
+			} else if strings.ToLower(key) == "outputformat" {
+				if elemValType == "string" {
+					outputFormat = strings.ToLower(fmt.Sprintf("%v", value))
+					if !isValidOutputFormat(outputFormat) {
+						return nil, errors.New("Unable to parse command: shell.outputFormat, with value " + outputFormat + ", expected one of: " + strings.Join(OUTPUT_FORMATS, ", "))
+					}
+				} else {
+					return nil, errors.New("Unable to parse command: shell.outputFormat, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "script" {
+				if elemValType == "string" {
+					script = fmt.Sprintf("%v", value)
+				} else {
+					return nil, errors.New("Unable to parse command: shell.script, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "interpreter" {
+				if elemValType == "string" {
+					interpreter = fmt.Sprintf("%v", value)
+				} else {
+					return nil, errors.New("Unable to parse command: shell.interpreter, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "scriptargs" {
+				if elemValType == "[]string" {
+					for _, val := range value.([]string) {
+						scriptArgs = append(scriptArgs, val)
+					}
+				} else if elemValType == "[]interface {}" {
+					for _, val := range value.([]interface{}) {
+						scriptArgs = append(scriptArgs, fmt.Sprintf("%v", val))
+					}
+				} else {
+					return nil, errors.New("Unable to parse command: shell.scriptArgs, with aguments of type " + elemValType + ", expected type []string")
+				}
+			} else if strings.ToLower(key) == "stdin" {
+				if elemValType == "string" {
+					stdin = fmt.Sprintf("%v", value)
+				} else {
+					return nil, errors.New("Unable to parse command: shell.stdin, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "stdinfile" {
+				if elemValType == "string" {
+					stdinFile = fmt.Sprintf("%v", value)
+				} else {
+					return nil, errors.New("Unable to parse command: shell.stdinFile, with aguments of type " + elemValType + ", expected type string")
+				}
+			} else if strings.ToLower(key) == "stream" {
+				if elemValType == "string" {
+					bl, err := strconv.ParseBool(fmt.Sprintf("%v", value))
+					if err != nil {
+						return nil, errors.New("Error parsing command: shell.stream, cause: " + err.Error())
+					}
+					stream = bl
+				} else if elemValType == "bool" {
+					stream = value.(bool)
+				} else {
+					return nil, errors.New("Unable to parse command: shell.stream, with aguments of type " + elemValType + ", expected type bool or string")
+				}
+			} else if strings.ToLower(key) == "parallel" {
+				count, err := strconv.Atoi(fmt.Sprintf("%v", value))
+				if err != nil || count < 1 {
+					return nil, errors.New("Unable to parse command: shell.parallel, with aguments of type " + elemValType + ", expected a positive int")
+				}
+				parallel = count
+			} else if strings.ToLower(key) == "secretvars" {
+				if elemValType == "[]string" {
+					for _, val := range value.([]string) {
+						secretVars = append(secretVars, val)
+					}
+				} else if elemValType == "[]interface {}" {
+					for _, val := range value.([]interface{}) {
+						secretVars = append(secretVars, fmt.Sprintf("%v", val))
+					}
+				} else {
+					return nil, errors.New("Unable to parse command: shell.secretVars, with aguments of type " + elemValType + ", expected type []string")
+				}
+			} else if strings.ToLower(key) == "nolog" {
+				if elemValType == "string" {
+					bl, err := strconv.ParseBool(fmt.Sprintf("%v", value))
+					if err != nil {
+						return nil, errors.New("Error parsing command: shell.noLog, cause: " + err.Error())
+					}
+					noLog = bl
+				} else if elemValType == "bool" {
+					noLog = value.(bool)
+				} else {
+					return nil, errors.New("Unable to parse command: shell.noLog, with aguments of type " + elemValType + ", expected type bool or string")
+				}
 			} else if strings.ToLower(key) == "withvars" {
 				if elemValType == "[]string" {
 					for _, val := range value.([]string) {
@@ -307,19 +655,55 @@ func (shell *shellCommand) Convert(cmdValues interface{}) (threads.StepRunnable,
 	} else {
 		return nil, errors.New("Unable to parse command: shell, with aguments of type " + valType + ", expected type map[string]interfce{}")
 	}
-	if exec == "" {
-		return nil, errors.New("Missing command: shell.exec -> mandatory field")
+	if exec == "" && len(args) == 0 && script == "" {
+		return nil, errors.New("Missing command: shell.exec or shell.script -> mandatory field")
 
 	}
+	if script != "" && (exec != "" || len(args) > 0) {
+		return nil, errors.New("Conflicting commands: shell.exec and shell.script cannot be used together")
+	}
+	if script == "" && len(scriptArgs) > 0 {
+		return nil, errors.New("Conflicting commands: shell.scriptArgs requires shell.script, use the shell.exec list for the command arguments")
+	}
+	if stdin != "" && stdinFile != "" {
+		return nil, errors.New("Conflicting commands: shell.stdin and shell.stdinFile cannot be used together")
+	}
 	if superError != nil {
 		return nil, superError
 	}
 	runnable := &shellCommand{
 		Exec:         exec,
+		Args:         args,
 		RunAs:        runAs,
 		AsRoot:       asRoot,
+		Escalation:   escalation,
+		PasswordVar:  passwordVar,
+		Env:          env,
+		Chdir:        chdir,
+		Timeout:      timeout,
+		Retries:      retries,
+		Delay:        delay,
+		Until:        until,
+		UntilRc:      untilRc,
+		Creates:      guards["creates"],
+		Removes:      guards["removes"],
+		Unless:       guards["unless"],
+		OnlyIf:       guards["onlyif"],
+		SuccessCodes: successCodes,
+		FailedWhen:   failedWhen,
+		ChangedWhen:  changedWhen,
+		OutputFormat: outputFormat,
+		Script:       script,
+		Interpreter:  interpreter,
+		ScriptArgs:   scriptArgs,
+		Stdin:        stdin,
+		StdinFile:    stdinFile,
+		Stream:       stream,
+		Parallel:     parallel,
+		SecretVars:   secretVars,
+		NoLog:        noLog,
 		WithVars:     withVars,
-		WithList:     nil,
+		WithList:     withList,
 		SaveState:    asVar,
 		host:         defaults.HostValue{},
 		session:      shell.session,
@@ -0,0 +1,44 @@
+package shell
+
+import (
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"strings"
+)
+
+// Replaces the '{{ var }}' placeholders with the session variables listed in WithVars
+func (shell *shellCommand) substituteVars(text string) string {
+	if shell.WithVars != nil && len(shell.WithVars) > 0 {
+		for _, varKey := range shell.WithVars {
+			varValue, varValueErr := shell.session.GetVar(varKey)
+			if varValueErr == nil {
+				text = strings.ReplaceAll(text, "{{ "+varKey+" }}", varValue)
+			}
+		}
+	}
+	return text
+}
+
+// Command template, before any item or variable substitution
+func (shell *shellCommand) commandTemplate() string {
+	if shell.Script != "" {
+		return strings.TrimSpace(shell.Interpreter + " " + shell.Script + " " + strings.Join(shell.ScriptArgs, " "))
+	}
+	if len(shell.Args) > 0 {
+		return strings.Join(shell.Args, " ")
+	}
+	return shell.Exec
+}
+
+// Renders the command for the given list item, in argument-vector mode each
+// argument is quoted after substitution, so values can't be reinterpreted
+// by the remote shell
+func (shell *shellCommand) renderCommand(item string) string {
+	if len(shell.Args) > 0 {
+		var args []string = make([]string, 0)
+		for _, arg := range shell.Args {
+			args = append(args, common.ShellQuote(shell.substituteVars(strings.ReplaceAll(arg, "{{ item }}", item))))
+		}
+		return shell.wrapEnvironment(strings.Join(args, " "), item)
+	}
+	return shell.wrapEnvironment(shell.substituteVars(strings.ReplaceAll(shell.Exec, "{{ item }}", item)), item)
+}
@@ -0,0 +1,79 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var ENV_NAME_REGEXP *regexp.Regexp = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+// Environment names containing one of these words have their values masked in logs
+var SECRET_ENV_WORDS []string = []string{"PASS", "SECRET", "TOKEN", "KEY", "CREDENTIAL"}
+
+func parseEnv(value interface{}) (map[string]string, error) {
+	values, ok := common.ToStringMap(value)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Unable to parse command: shell.env, with aguments of type %T, expected type map[string]string", value))
+	}
+	var env map[string]string = make(map[string]string)
+	for key, val := range values {
+		if !ENV_NAME_REGEXP.MatchString(key) {
+			return nil, errors.New("Unable to parse command: shell.env, invalid environment variable name: " + key)
+		}
+		env[key] = fmt.Sprintf("%v", val)
+	}
+	return env, nil
+}
+
+func isSecretEnv(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, word := range SECRET_ENV_WORDS {
+		if strings.Contains(upper, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedEnvKeys(env map[string]string) []string {
+	var keys []string = make([]string, 0)
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Environment description for logs, with secret values masked
+func (shell *shellCommand) envString() string {
+	var pairs []string = make([]string, 0)
+	for _, key := range sortedEnvKeys(shell.Env) {
+		if shell.NoLog || isSecretEnv(key) {
+			pairs = append(pairs, key+"=******")
+		} else {
+			pairs = append(pairs, key+"="+shell.Env[key])
+		}
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// Prefixes the command with the working directory change and the environment
+// exports, resolving list item and session variables in both
+func (shell *shellCommand) wrapEnvironment(command string, item string) string {
+	var prefix string = ""
+	if shell.Chdir != "" {
+		prefix += "cd " + common.ShellQuote(shell.substituteVars(strings.ReplaceAll(shell.Chdir, "{{ item }}", item))) + " && "
+	}
+	if len(shell.Env) > 0 {
+		var pairs []string = make([]string, 0)
+		for _, key := range sortedEnvKeys(shell.Env) {
+			pairs = append(pairs, key+"="+common.ShellQuote(shell.substituteVars(strings.ReplaceAll(shell.Env[key], "{{ item }}", item))))
+		}
+		prefix += "export " + strings.Join(pairs, " ") + " && "
+	}
+	return prefix + command
+}
@@ -0,0 +1,121 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gookit/color"
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Returned, wrapped with the command details, when a shell step exceeds its timeout
+var TIMEOUT_ERROR error = errors.New("Shell command timed out")
+
+// Returned, wrapped with the command details, when a shell step is stopped or killed
+var STOPPED_ERROR error = errors.New("Shell command stopped")
+
+// Grace period between the termination signal and the kill signal
+var TERMINATION_GRACE_PERIOD time.Duration = 5 * time.Second
+
+// Creates the step execution context, honoring the timeout and exposing
+// the cancellation to Stop and Kill
+func (shell *shellCommand) newContext() (context.Context, context.CancelFunc) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if shell.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), shell.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	shell._lock.Lock()
+	shell._cancel = cancel
+	shell._forceKill = false
+	shell._lock.Unlock()
+	return ctx, cancel
+}
+
+func (shell *shellCommand) cancel(force bool) {
+	shell._lock.Lock()
+	defer shell._lock.Unlock()
+	shell._forceKill = shell._forceKill || force
+	if shell._cancel != nil {
+		shell._cancel()
+	}
+}
+
+// Executes the escalated command on the remote host, the command runs in its
+// own session so on cancellation the whole process group can be terminated.
+// When streamed the output lines are forwarded to the logger while captured
+func (shell *shellCommand) execute(ctx context.Context, command string, item string, stream bool) (*execResult, error) {
+	shell._lock.Lock()
+	shell._execCount++
+	pidFile := "/tmp/.go-deploy-" + shell.uuid + "-" + strconv.Itoa(shell._execCount) + ".pid"
+	shell._lock.Unlock()
+	wrapped := "setsid sh -c " + common.ShellQuote(command) + " & __gd_pid=$!; echo $__gd_pid > " + common.ShellQuote(pidFile) +
+		"; wait $__gd_pid; __gd_rc=$?; rm -f " + common.ShellQuote(pidFile) + "; exit $__gd_rc"
+	var stdout, stderr *syncBuffer = &syncBuffer{}, &syncBuffer{}
+	var result *execResult = &execResult{
+		Start: time.Now(),
+	}
+	var outWriter, errWriter io.Writer = stdout, stderr
+	var outStreamer, errStreamer *lineStreamer
+	if stream {
+		outStreamer, errStreamer = shell.newStreamers(stdout, stderr, item)
+		outWriter, errWriter = outStreamer, errStreamer
+	}
+	var outcome chan error = make(chan error, 1)
+	go func() {
+		script := shell.client.Script(wrapped)
+		script.SetStdio(outWriter, errWriter)
+		outcome <- script.Run()
+	}()
+	var err error
+	select {
+	case err = <-outcome:
+		result.Rc = exitCode(err)
+	case <-ctx.Done():
+		shell.terminate(pidFile)
+		err = shell.contextError(ctx)
+		result.Rc = -1
+	}
+	result.End = time.Now()
+	if stream {
+		outStreamer.Flush()
+		errStreamer.Flush()
+	}
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	return result, err
+}
+
+func (shell *shellCommand) contextError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%w after %s, on host: %s", TIMEOUT_ERROR, shell.Timeout.String(), shell.host.Name)
+	}
+	return fmt.Errorf("%w, on host: %s", STOPPED_ERROR, shell.host.Name)
+}
+
+// Terminates the remote process group recorded in the pid file
+func (shell *shellCommand) terminate(pidFile string) {
+	shell._lock.Lock()
+	force := shell._forceKill
+	shell._lock.Unlock()
+	group := "-- -$(cat " + common.ShellQuote(pidFile) + ")"
+	var command string
+	if force {
+		command = "kill -KILL " + group
+	} else {
+		command = "kill -TERM " + group + " && sleep " + strconv.Itoa(int(TERMINATION_GRACE_PERIOD.Seconds())) + "; kill -KILL " + group
+	}
+	command = "test -f " + common.ShellQuote(pidFile) + " && { " + command + " 2>/dev/null; rm -f " + common.ShellQuote(pidFile) + "; }"
+	if _, errKill := shell.client.Script(shell.escalate(command)).ExecuteWithFullOutput(); errKill != nil {
+		if shell._logger != nil {
+			shell._logger.Warnf("Unable to terminate remote process group, pid file: %s", pidFile)
+		} else {
+			color.LightYellow.Printf("Unable to terminate remote process group, pid file: %s\n", pidFile)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+* Boolean expression evaluated against the command result, for instance:
+*   rc != 0 && not (stderr contains 'already exists')
+*   rc == 1 || stdout matches '^changed'
+* Operands: rc, stdout, stderr, integers and quoted strings
+* Operators: ==, !=, <, <=, >, >=, contains, matches, &&/and, ||/or, !/not, parentheses
+ */
+// Operators made of two characters, single '=', '&' and '|' are not operators
+var TWO_CHAR_OPERATORS []string = []string{"==", "!=", "<=", ">=", "&&", "||"}
+
+func isTwoCharOperator(token string) bool {
+	for _, op := range TWO_CHAR_OPERATORS {
+		if op == token {
+			return true
+		}
+	}
+	return false
+}
+
+type resultExpr struct {
+	source string
+	root   exprNode
+}
+
+type exprNode interface {
+	eval(result *execResult) (interface{}, error)
+}
+
+type exprLiteral struct {
+	value interface{}
+}
+
+type exprIdent struct {
+	name string
+}
+
+type exprNot struct {
+	operand exprNode
+}
+
+type exprBinary struct {
+	op    string
+	left  exprNode
+	right exprNode
+}
+
+func (expr resultExpr) String() string {
+	return expr.source
+}
+
+func parseResultExpr(source string) (*resultExpr, error) {
+	tokens, err := tokenizeExpr(source)
+	if err != nil {
+		return nil, err
+	}
+	parser := &exprParser{tokens: tokens}
+	root, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return nil, errors.New("Unexpected token '" + parser.tokens[parser.pos] + "' in expression: " + source)
+	}
+	return &resultExpr{source: source, root: root}, nil
+}
+
+// Evaluates the expression, which must produce a boolean value
+func (expr *resultExpr) evaluate(result *execResult) (bool, error) {
+	value, err := expr.root.eval(result)
+	if err != nil {
+		return false, errors.New("Error evaluating expression: " + expr.source + ", cause: " + err.Error())
+	}
+	bl, ok := value.(bool)
+	if !ok {
+		return false, errors.New("Expression doesn't produce a boolean value: " + expr.source)
+	}
+	return bl, nil
+}
+
+func tokenizeExpr(source string) ([]string, error) {
+	var tokens []string = make([]string, 0)
+	var runes []rune = []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			i++
+		case r == '\'' || r == '"':
+			var text strings.Builder
+			text.WriteRune(r)
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				text.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, errors.New("Unterminated string in expression: " + source)
+			}
+			tokens = append(tokens, text.String())
+			i = j + 1
+		case strings.ContainsRune("=!<>&|", r):
+			if i+1 < len(runes) && isTwoCharOperator(string(runes[i:i+2])) {
+				tokens = append(tokens, string(runes[i:i+2]))
+				i += 2
+			} else if r == '!' || r == '<' || r == '>' {
+				tokens = append(tokens, string(r))
+				i++
+			} else {
+				return nil, errors.New("Unexpected character '" + string(r) + "' in expression: " + source)
+			}
+		case r == '(' || r == ')':
+			tokens = append(tokens, string(r))
+			i++
+		case r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+			j := i + 1
+			for ; j < len(runes) && (runes[j] == '_' || runes[j] >= '0' && runes[j] <= '9' || runes[j] >= 'a' && runes[j] <= 'z' || runes[j] >= 'A' && runes[j] <= 'Z'); j++ {
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		default:
+			return nil, errors.New("Unexpected character '" + string(r) + "' in expression: " + source)
+		}
+	}
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (parser *exprParser) peek() string {
+	if parser.pos < len(parser.tokens) {
+		return parser.tokens[parser.pos]
+	}
+	return ""
+}
+
+func (parser *exprParser) next() string {
+	token := parser.peek()
+	parser.pos++
+	return token
+}
+
+func (parser *exprParser) parseOr() (exprNode, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for parser.peek() == "||" || strings.ToLower(parser.peek()) == "or" {
+		parser.next()
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (parser *exprParser) parseAnd() (exprNode, error) {
+	left, err := parser.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for parser.peek() == "&&" || strings.ToLower(parser.peek()) == "and" {
+		parser.next()
+		right, err := parser.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (parser *exprParser) parseNot() (exprNode, error) {
+	if parser.peek() == "!" || strings.ToLower(parser.peek()) == "not" {
+		parser.next()
+		operand, err := parser.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNot{operand: operand}, nil
+	}
+	return parser.parseComparison()
+}
+
+func (parser *exprParser) parseComparison() (exprNode, error) {
+	left, err := parser.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	op := strings.ToLower(parser.peek())
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "contains", "matches":
+		parser.next()
+		right, err := parser.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "matches" {
+			if literal, ok := right.(*exprLiteral); ok {
+				if _, errRe := regexp.Compile(fmt.Sprintf("%v", literal.value)); errRe != nil {
+					return nil, errors.New("Invalid regular expression in expression, cause: " + errRe.Error())
+				}
+			}
+		}
+		return &exprBinary{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (parser *exprParser) parsePrimary() (exprNode, error) {
+	token := parser.next()
+	switch {
+	case token == "":
+		return nil, errors.New("Unexpected end of expression")
+	case token == "(":
+		node, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if parser.next() != ")" {
+			return nil, errors.New("Missing closing parenthesis in expression")
+		}
+		return node, nil
+	case token[0] == '\'' || token[0] == '"':
+		return &exprLiteral{value: token[1:]}, nil
+	case strings.ToLower(token) == "true" || strings.ToLower(token) == "false":
+		return &exprLiteral{value: strings.ToLower(token) == "true"}, nil
+	}
+	if number, err := strconv.Atoi(token); err == nil {
+		return &exprLiteral{value: number}, nil
+	}
+	switch strings.ToLower(token) {
+	case "rc", "stdout", "stderr":
+		return &exprIdent{name: strings.ToLower(token)}, nil
+	}
+	return nil, errors.New("Unknown operand '" + token + "', expected rc, stdout, stderr, a number or a quoted string")
+}
+
+func (node *exprLiteral) eval(result *execResult) (interface{}, error) {
+	return node.value, nil
+}
+
+func (node *exprIdent) eval(result *execResult) (interface{}, error) {
+	switch node.name {
+	case "rc":
+		return result.Rc, nil
+	case "stdout":
+		return result.Stdout, nil
+	}
+	return result.Stderr, nil
+}
+
+func (node *exprNot) eval(result *execResult) (interface{}, error) {
+	value, err := node.operand.eval(result)
+	if err != nil {
+		return nil, err
+	}
+	bl, ok := value.(bool)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("operator not requires a boolean operand, found: %v", value))
+	}
+	return !bl, nil
+}
+
+func (node *exprBinary) eval(result *execResult) (interface{}, error) {
+	left, err := node.left.eval(result)
+	if err != nil {
+		return nil, err
+	}
+	if node.op == "&&" || node.op == "||" {
+		lbl, ok := left.(bool)
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("operator %s requires boolean operands, found: %v", node.op, left))
+		}
+		if (node.op == "&&" && !lbl) || (node.op == "||" && lbl) {
+			return lbl, nil
+		}
+		right, err := node.right.eval(result)
+		if err != nil {
+			return nil, err
+		}
+		rbl, ok := right.(bool)
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("operator %s requires boolean operands, found: %v", node.op, right))
+		}
+		return rbl, nil
+	}
+	right, err := node.right.eval(result)
+	if err != nil {
+		return nil, err
+	}
+	switch node.op {
+	case "contains":
+		return strings.Contains(fmt.Sprintf("%v", left), fmt.Sprintf("%v", right)), nil
+	case "matches":
+		re, err := regexp.Compile(fmt.Sprintf("%v", right))
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(fmt.Sprintf("%v", left)), nil
+	case "==":
+		return fmt.Sprintf("%v", left) == fmt.Sprintf("%v", right), nil
+	case "!=":
+		return fmt.Sprintf("%v", left) != fmt.Sprintf("%v", right), nil
+	}
+	lnum, lok := left.(int)
+	rnum, rok := right.(int)
+	if !lok || !rok {
+		return nil, errors.New(fmt.Sprintf("operator %s requires numeric operands, found: %v and %v", node.op, left, right))
+	}
+	switch node.op {
+	case "<":
+		return lnum < rnum, nil
+	case "<=":
+		return lnum <= rnum, nil
+	case ">":
+		return lnum > rnum, nil
+	}
+	return lnum >= rnum, nil
+}
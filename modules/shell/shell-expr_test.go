@@ -0,0 +1,140 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvaluateResultExpr(t *testing.T) {
+	var result *execResult = &execResult{
+		Rc:     1,
+		Stdout: "changed: 3 files",
+		Stderr: "it's already there",
+	}
+	var cases []struct {
+		source   string
+		expected bool
+	} = []struct {
+		source   string
+		expected bool
+	}{
+		{"rc == 1", true},
+		{"rc != 1", false},
+		{"rc < 2", true},
+		{"rc <= 1", true},
+		{"rc > 1", false},
+		{"rc >= 2", false},
+		{"rc == -1", false},
+		{"true", true},
+		{"FALSE", false},
+		{"stdout contains 'changed'", true},
+		{"stdout contains \"3 files\"", true},
+		{"stderr contains 'it\\'s'", true},
+		{"stderr == ''", false},
+		{"stdout matches '^changed: [0-9]+'", true},
+		{"stdout matches '^unchanged'", false},
+		{"not rc == 0", true},
+		{"!(rc == 1)", false},
+		{"not not true", true},
+		{"rc == 0 || rc == 1 && stdout contains 'changed'", true},
+		{"rc == 1 || rc == 0 && stdout contains 'nothing'", true},
+		{"(rc == 1 || rc == 0) && stdout contains 'nothing'", false},
+		{"rc == 0 or rc == 1 and not stderr contains 'error'", true},
+		{"rc != 0 && not (stderr contains 'already')", false},
+		{"RC == 1 AND Stdout contains 'files'", true},
+	}
+	for _, tc := range cases {
+		expr, err := parseResultExpr(tc.source)
+		if err != nil {
+			t.Errorf("parseResultExpr(%q) unexpected error: %v", tc.source, err)
+			continue
+		}
+		value, err := expr.evaluate(result)
+		if err != nil {
+			t.Errorf("evaluate(%q) unexpected error: %v", tc.source, err)
+			continue
+		}
+		if value != tc.expected {
+			t.Errorf("evaluate(%q) = %v, expected %v", tc.source, value, tc.expected)
+		}
+	}
+}
+
+func TestParseResultExprErrors(t *testing.T) {
+	var cases []struct {
+		source  string
+		message string
+	} = []struct {
+		source  string
+		message string
+	}{
+		{"rc = 1", "Unexpected character '='"},
+		{"rc == 1 & rc == 2", "Unexpected character '&'"},
+		{"rc == 1 | rc == 2", "Unexpected character '|'"},
+		{"stdout contains 'open", "Unterminated string"},
+		{"(rc == 1", "Missing closing parenthesis"},
+		{"rc == 1)", "Unexpected token ')'"},
+		{"rc ==", "Unexpected end of expression"},
+		{"exit == 1", "Unknown operand 'exit'"},
+		{"stdout matches '['", "Invalid regular expression"},
+		{"rc == 1 # comment", "Unexpected character '#'"},
+		{"", "Unexpected end of expression"},
+	}
+	for _, tc := range cases {
+		_, err := parseResultExpr(tc.source)
+		if err == nil {
+			t.Errorf("parseResultExpr(%q) expected error containing %q", tc.source, tc.message)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.message) {
+			t.Errorf("parseResultExpr(%q) error = %q, expected to contain %q", tc.source, err.Error(), tc.message)
+		}
+	}
+}
+
+func TestEvaluateResultExprErrors(t *testing.T) {
+	var result *execResult = &execResult{Rc: 0, Stdout: "ok"}
+	var cases []struct {
+		source  string
+		message string
+	} = []struct {
+		source  string
+		message string
+	}{
+		{"rc", "doesn't produce a boolean value"},
+		{"'text'", "doesn't produce a boolean value"},
+		{"not rc", "operator not requires a boolean operand"},
+		{"rc && true", "operator && requires boolean operands"},
+		{"false || stdout", "operator || requires boolean operands"},
+		{"stdout < 1", "operator < requires numeric operands"},
+	}
+	for _, tc := range cases {
+		expr, err := parseResultExpr(tc.source)
+		if err != nil {
+			t.Errorf("parseResultExpr(%q) unexpected error: %v", tc.source, err)
+			continue
+		}
+		_, err = expr.evaluate(result)
+		if err == nil {
+			t.Errorf("evaluate(%q) expected error containing %q", tc.source, tc.message)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.message) {
+			t.Errorf("evaluate(%q) error = %q, expected to contain %q", tc.source, err.Error(), tc.message)
+		}
+	}
+}
+
+func TestEvaluateShortCircuit(t *testing.T) {
+	var result *execResult = &execResult{Rc: 0}
+	// The right operands are never evaluated, so their type errors are not raised
+	for _, source := range []string{"false && rc", "true || stdout"} {
+		expr, err := parseResultExpr(source)
+		if err != nil {
+			t.Fatalf("parseResultExpr(%q) unexpected error: %v", source, err)
+		}
+		if _, err = expr.evaluate(result); err != nil {
+			t.Errorf("evaluate(%q) unexpected error: %v", source, err)
+		}
+	}
+}
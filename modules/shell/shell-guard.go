@@ -0,0 +1,83 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"github.com/gookit/color"
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"strings"
+)
+
+// Runs a guard probe on the remote host, it returns true when the probe succeeded,
+// while an error is returned only when the probe couldn't run
+func (shell *shellCommand) probe(ctx context.Context, command string, item string) (bool, error) {
+	result, err := shell.execute(ctx, shell.escalate(shell.wrapEnvironment(command, item)), item, false)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, TIMEOUT_ERROR) || errors.Is(err, STOPPED_ERROR) || result.Rc < 0 {
+		return false, errors.New(shell.mask("Unable to check guard: " + command + ", Error Details: " + err.Error()))
+	}
+	return false, nil
+}
+
+// Checks creates, removes, unless and onlyIf guards, it returns a not empty reason
+// when the command must be skipped because the work is already done
+func (shell *shellCommand) skipReason(ctx context.Context, item string) (string, error) {
+	resolve := func(text string) string {
+		return shell.substituteVars(strings.ReplaceAll(text, "{{ item }}", item))
+	}
+	if shell.Creates != "" {
+		path := resolve(shell.Creates)
+		exists, err := shell.probe(ctx, "test -e "+common.ShellQuote(path), item)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return "path " + path + " already exists", nil
+		}
+	}
+	if shell.Removes != "" {
+		path := resolve(shell.Removes)
+		exists, err := shell.probe(ctx, "test -e "+common.ShellQuote(path), item)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return "path " + path + " doesn't exist", nil
+		}
+	}
+	if shell.Unless != "" {
+		succeeded, err := shell.probe(ctx, resolve(shell.Unless), item)
+		if err != nil {
+			return "", err
+		}
+		if succeeded {
+			return "unless command succeeded", nil
+		}
+	}
+	if shell.OnlyIf != "" {
+		succeeded, err := shell.probe(ctx, resolve(shell.OnlyIf), item)
+		if err != nil {
+			return "", err
+		}
+		if !succeeded {
+			return "onlyIf command failed", nil
+		}
+	}
+	return "", nil
+}
+
+// Checks the guards for the given item, logging the reason when the command is skipped
+func (shell *shellCommand) checkGuards(ctx context.Context, item string) (bool, error) {
+	reason, err := shell.skipReason(ctx, item)
+	if err != nil || reason == "" {
+		return false, err
+	}
+	if shell._logger != nil {
+		shell._logger.Infof("Command skipped, item: %s, reason: %s -> changed: false", shell.mask(item), shell.mask(reason))
+	} else {
+		color.LightYellow.Printf("Command skipped, item: %s, reason: %s -> changed: false\n", shell.mask(item), shell.mask(reason))
+	}
+	return true, nil
+}
@@ -0,0 +1,102 @@
+package shell
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"gopkg.in/yaml.v2"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Accepted values for shell.outputFormat
+var OUTPUT_FORMATS []string = []string{"json", "yaml", "lines", "kv"}
+
+func isValidOutputFormat(format string) bool {
+	for _, allowed := range OUTPUT_FORMATS {
+		if allowed == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Decodes the command standard output according to the given format
+func decodeOutput(format string, stdout string) (interface{}, error) {
+	var decoded interface{}
+	switch format {
+	case "json":
+		// Numbers are kept as written, instead of float64 values as 1.5e+06
+		decoder := json.NewDecoder(strings.NewReader(stdout))
+		decoder.UseNumber()
+		if err := decoder.Decode(&decoded); err != nil {
+			return nil, errors.New("Unable to parse command output as json, cause: " + err.Error())
+		}
+		if decoder.More() {
+			return nil, errors.New("Unable to parse command output as json, cause: unexpected data after the json value")
+		}
+	case "yaml":
+		if err := yaml.Unmarshal([]byte(stdout), &decoded); err != nil {
+			return nil, errors.New("Unable to parse command output as yaml, cause: " + err.Error())
+		}
+	case "lines":
+		var lines []interface{} = make([]interface{}, 0)
+		for _, line := range strings.Split(stdout, "\n") {
+			if strings.TrimSpace(line) != "" {
+				lines = append(lines, strings.TrimRight(line, "\r"))
+			}
+		}
+		decoded = lines
+	case "kv":
+		var values map[string]interface{} = make(map[string]interface{})
+		for index, line := range strings.Split(stdout, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			idx := strings.Index(line, "=")
+			if idx <= 0 {
+				return nil, errors.New("Unable to parse command output as kv, line " + strconv.Itoa(index+1) + " is not in key=value form: " + line)
+			}
+			values[strings.TrimSpace(line[:idx])] = strings.Trim(strings.TrimSpace(line[idx+1:]), "\"'")
+		}
+		decoded = values
+	default:
+		return nil, errors.New("Unknown output format: " + format + ", expected one of: " + strings.Join(OUTPUT_FORMATS, ", "))
+	}
+	return decoded, nil
+}
+
+// Flattens the decoded structure into session variables: map fields are joined
+// with dots, list elements are indexed with brackets and have a length variable
+func flattenOutput(prefix string, value interface{}, vars map[string]string) {
+	if values, ok := common.ToStringMap(value); ok {
+		var keys []string = make([]string, 0)
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			flattenOutput(prefix+"."+key, values[key], vars)
+		}
+		return
+	}
+	if list, ok := value.([]interface{}); ok {
+		for index, elem := range list {
+			flattenOutput(prefix+"["+strconv.Itoa(index)+"]", elem, vars)
+		}
+		vars[prefix+".length"] = strconv.Itoa(len(list))
+		return
+	}
+	if value == nil {
+		vars[prefix] = ""
+		return
+	}
+	if number, ok := value.(float64); ok {
+		vars[prefix] = strconv.FormatFloat(number, 'f', -1, 64)
+		return
+	}
+	vars[prefix] = fmt.Sprintf("%v", value)
+}
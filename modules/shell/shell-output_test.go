@@ -0,0 +1,76 @@
+package shell
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeOutput(t *testing.T) {
+	var cases []struct {
+		format   string
+		stdout   string
+		expected map[string]string
+	} = []struct {
+		format   string
+		stdout   string
+		expected map[string]string
+	}{
+		{"json", `{"name": "app", "size": 1500000, "ratio": 0.25, "on": true, "none": null}`, map[string]string{
+			"out.name": "app", "out.size": "1500000", "out.ratio": "0.25", "out.on": "true", "out.none": "",
+		}},
+		{"json", `{"nodes": [{"ip": "10.0.0.1"}, {"ip": "10.0.0.2"}], "big": 12345678901234567890}`, map[string]string{
+			"out.nodes[0].ip": "10.0.0.1", "out.nodes[1].ip": "10.0.0.2", "out.nodes.length": "2", "out.big": "12345678901234567890",
+		}},
+		{"json", `["a", "b"]`, map[string]string{"out[0]": "a", "out[1]": "b", "out.length": "2"}},
+		{"yaml", "name: app\nport: 8080\ntags:\n  - web\n  - api\n", map[string]string{
+			"out.name": "app", "out.port": "8080", "out.tags[0]": "web", "out.tags[1]": "api", "out.tags.length": "2",
+		}},
+		{"yaml", "size: 1500000\nratio: 0.25\n", map[string]string{"out.size": "1500000", "out.ratio": "0.25"}},
+		{"lines", "first\r\n\n  \nsecond\n", map[string]string{"out[0]": "first", "out[1]": "second", "out.length": "2"}},
+		{"kv", "# comment\nNAME=app\n VERSION = \"1.2\" \n\nMODE='prod'\n", map[string]string{
+			"out.NAME": "app", "out.VERSION": "1.2", "out.MODE": "prod",
+		}},
+	}
+	for _, tc := range cases {
+		decoded, err := decodeOutput(tc.format, tc.stdout)
+		if err != nil {
+			t.Errorf("decodeOutput(%q, %q) unexpected error: %v", tc.format, tc.stdout, err)
+			continue
+		}
+		var vars map[string]string = make(map[string]string)
+		flattenOutput("out", decoded, vars)
+		if !reflect.DeepEqual(vars, tc.expected) {
+			t.Errorf("flattenOutput(decodeOutput(%q, %q)) = %v, expected %v", tc.format, tc.stdout, vars, tc.expected)
+		}
+	}
+}
+
+func TestDecodeOutputErrors(t *testing.T) {
+	var cases []struct {
+		format  string
+		stdout  string
+		message string
+	} = []struct {
+		format  string
+		stdout  string
+		message string
+	}{
+		{"json", `{"name": `, "Unable to parse command output as json"},
+		{"json", `{"a": 1} {"b": 2}`, "unexpected data after the json value"},
+		{"yaml", "name: [unclosed", "Unable to parse command output as yaml"},
+		{"kv", "NAME=app\njust text\n", "line 2 is not in key=value form"},
+		{"kv", "=value\n", "line 1 is not in key=value form"},
+		{"xml", "<a/>", "Unknown output format: xml"},
+	}
+	for _, tc := range cases {
+		_, err := decodeOutput(tc.format, tc.stdout)
+		if err == nil {
+			t.Errorf("decodeOutput(%q, %q) expected error containing %q", tc.format, tc.stdout, tc.message)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.message) {
+			t.Errorf("decodeOutput(%q, %q) error = %q, expected to contain %q", tc.format, tc.stdout, err.Error(), tc.message)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Runs the list items with at most Parallel items in flight, results keep one
+// entry per list item in list order and all the failures are reported together
+func (shell *shellCommand) runItemsParallel(ctx context.Context) ([]*execResult, error) {
+	var results []*execResult = make([]*execResult, len(shell.WithList))
+	var failures []error = make([]error, len(shell.WithList))
+	var semaphore chan bool = make(chan bool, shell.Parallel)
+	var group sync.WaitGroup
+	for index, listItem := range shell.WithList {
+		if ctx.Err() != nil {
+			failures[index] = shell.contextError(ctx)
+			break
+		}
+		semaphore <- true
+		group.Add(1)
+		go func(index int, item string) {
+			defer group.Done()
+			defer func() {
+				<-semaphore
+			}()
+			results[index], failures[index] = shell.runItem(ctx, item)
+		}(index, listItem)
+	}
+	group.Wait()
+	var messages []string = make([]string, 0)
+	for index, listItem := range shell.WithList {
+		results[index] = itemResult(listItem, results[index], failures[index])
+		if failures[index] != nil {
+			messages = append(messages, failures[index].Error())
+		}
+	}
+	if len(messages) > 0 {
+		return results, errors.New(strings.Join(messages, "\n"))
+	}
+	return results, nil
+}
@@ -0,0 +1,61 @@
+package shell
+
+import (
+	"github.com/gookit/color"
+	"github.com/hellgate75/go-deploy-modules/modules/privilege"
+)
+
+// Target user of the privilege escalation, empty when no escalation is required
+func (shell *shellCommand) escalationUser() string {
+	if shell.AsRoot {
+		return "root"
+	}
+	return shell.RunAs
+}
+
+// Password used by the privilege escalation, empty when not configured
+func (shell *shellCommand) escalationPassword() (string, error) {
+	return privilege.Password(shell.session, shell.PasswordVar)
+}
+
+// Uploads the sudo password helper for the whole step run, when a password is required
+func (shell *shellCommand) prepareEscalation() error {
+	if shell.escalationUser() == "" {
+		return nil
+	}
+	password, err := shell.escalationPassword()
+	if err != nil || password == "" {
+		return err
+	}
+	askPass, err := privilege.UploadAskPass(shell.client, shell.uuid, password)
+	if err != nil {
+		return err
+	}
+	shell._lock.Lock()
+	shell._askPass = askPass
+	shell._lock.Unlock()
+	return nil
+}
+
+func (shell *shellCommand) releaseEscalation() {
+	shell._lock.Lock()
+	askPass := shell._askPass
+	shell._askPass = ""
+	shell._lock.Unlock()
+	if err := privilege.RemoveAskPass(shell.client, askPass); err != nil {
+		if shell._logger != nil {
+			shell._logger.Warnf("Unable to remove escalation helper: %s", askPass)
+		} else {
+			color.LightYellow.Printf("Unable to remove escalation helper: %s\n", askPass)
+		}
+	}
+}
+
+// Wraps the command in the configured privilege escalation, the sudo password is
+// read from the helper uploaded by prepareEscalation
+func (shell *shellCommand) escalate(command string) string {
+	shell._lock.Lock()
+	askPass := shell._askPass
+	shell._lock.Unlock()
+	return privilege.Wrap(command, shell.escalationUser(), askPass)
+}
@@ -0,0 +1,31 @@
+package shell
+
+import (
+	"github.com/hellgate75/go-deploy-modules/modules/redact"
+)
+
+// Step secrets plus the escalation password and, with noLog, the environment values
+func (shell *shellCommand) secrets() []string {
+	var values []string = redact.Secrets(shell.session, shell.SecretVars, shell.WithVars, shell.NoLog)
+	if password, err := shell.escalationPassword(); err == nil && password != "" {
+		values = append(values, password)
+	}
+	if shell.NoLog {
+		for _, value := range shell.Env {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (shell *shellCommand) mask(text string) string {
+	return redact.Mask(text, shell.secrets())
+}
+
+// Command description for logs, hidden with noLog
+func (shell *shellCommand) loggableCommand() string {
+	if shell.NoLog {
+		return redact.MASK
+	}
+	return shell.mask(shell.commandTemplate())
+}
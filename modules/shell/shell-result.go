@@ -0,0 +1,175 @@
+package shell
+
+import (
+	"bytes"
+	"github.com/gookit/color"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+* Remote command execution result
+ */
+type execResult struct {
+	Item    string
+	Rc      int
+	Stdout  string
+	Stderr  string
+	Start   time.Time
+	End     time.Time
+	Skipped bool
+	Changed bool
+	Failed  bool
+	Data    interface{}
+}
+
+// Result recorded for a list item, marked failed on error. Items which couldn't run,
+// as on guard errors, get a placeholder result so items keep their list index
+func itemResult(item string, result *execResult, err error) *execResult {
+	if result == nil {
+		now := time.Now()
+		result = &execResult{Item: item, Rc: -1, Start: now, End: now, Failed: true}
+	}
+	result.Failed = result.Failed || err != nil
+	return result
+}
+
+func (result *execResult) Duration() time.Duration {
+	return result.End.Sub(result.Start)
+}
+
+// Combined output, as returned by the remote script full output
+func (result *execResult) Output() []byte {
+	return []byte(result.Stdout + result.Stderr)
+}
+
+/*
+* Buffer safe for concurrent writes from the remote script streams
+ */
+type syncBuffer struct {
+	buffer bytes.Buffer
+	lock   sync.Mutex
+}
+
+func (buffer *syncBuffer) Write(p []byte) (int, error) {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+	return buffer.buffer.Write(p)
+}
+
+func (buffer *syncBuffer) String() string {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+	return buffer.buffer.String()
+}
+
+// Session variable suffixes and values of the result
+func (result *execResult) toVars() map[string]string {
+	var vars map[string]string = map[string]string{
+		"item":     result.Item,
+		"rc":       strconv.Itoa(result.Rc),
+		"stdout":   strings.TrimSpace(result.Stdout),
+		"stderr":   strings.TrimSpace(result.Stderr),
+		"start":    result.Start.Format(time.RFC3339),
+		"end":      result.End.Format(time.RFC3339),
+		"duration": result.Duration().String(),
+		"skipped":  strconv.FormatBool(result.Skipped),
+		"changed":  strconv.FormatBool(result.Changed),
+		"failed":   strconv.FormatBool(result.Failed),
+	}
+	if result.Data != nil {
+		flattenOutput("data", result.Data, vars)
+	}
+	return vars
+}
+
+func (shell *shellCommand) saveVar(varName string, value string) {
+	done := shell.session.SetVar(varName, value)
+	if !done {
+		if shell._logger != nil {
+			shell._logger.Warnf("Unable to save state: %s", varName)
+		} else {
+			color.LightYellow.Printf("Unable to save state: %s\n", varName)
+		}
+	}
+}
+
+// Registers the results under the SaveState variable: the variable itself keeps the
+// trimmed standard output, while rc, stdout, stderr, start, end and duration are
+// registered as sub-variables, and per item under items[N] when a list is used.
+// Output decoded by outputFormat is registered under data
+func (shell *shellCommand) saveResults(results []*execResult, withList bool) {
+	if shell.SaveState == "" || len(results) == 0 {
+		return
+	}
+	var stdout, stderr []string = make([]string, 0), make([]string, 0)
+	var rc int = 0
+	for index, result := range results {
+		if strings.TrimSpace(result.Stdout) != "" {
+			stdout = append(stdout, strings.TrimSpace(result.Stdout))
+		}
+		if strings.TrimSpace(result.Stderr) != "" {
+			stderr = append(stderr, strings.TrimSpace(result.Stderr))
+		}
+		if result.Rc != 0 {
+			rc = result.Rc
+		}
+		if withList {
+			for key, value := range result.toVars() {
+				shell.saveVar(shell.SaveState+".items["+strconv.Itoa(index)+"]."+key, value)
+			}
+		}
+	}
+	var vars map[string]string = map[string]string{
+		"rc":       strconv.Itoa(rc),
+		"stdout":   strings.Join(stdout, "\n"),
+		"stderr":   strings.Join(stderr, "\n"),
+		"start":    results[0].Start.Format(time.RFC3339),
+		"end":      results[len(results)-1].End.Format(time.RFC3339),
+		"duration": results[len(results)-1].End.Sub(results[0].Start).String(),
+		"changed":  strconv.FormatBool(shell.changed),
+	}
+	if withList {
+		vars["items.length"] = strconv.Itoa(len(results))
+	} else {
+		vars["skipped"] = strconv.FormatBool(results[0].Skipped)
+		if results[0].Data != nil {
+			flattenOutput("data", results[0].Data, vars)
+		}
+	}
+	shell.saveVar(shell.SaveState, vars["stdout"])
+	for key, value := range vars {
+		shell.saveVar(shell.SaveState+"."+key, value)
+	}
+}
+
+// Checks whether the result is a failure: failedWhen takes precedence over successCodes,
+// and without both any not zero exit code is a failure. Errors without an exit code, as
+// connection errors or unsatisfied until conditions, are always failures
+func (shell *shellCommand) isFailed(result *execResult, err error) (bool, error) {
+	if err != nil && (result.Rc < 0 || shell.hasUntil()) {
+		return true, nil
+	}
+	if shell.FailedWhen != nil {
+		return shell.FailedWhen.evaluate(result)
+	}
+	if len(shell.SuccessCodes) > 0 {
+		for _, code := range shell.SuccessCodes {
+			if code == result.Rc {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	return err != nil, nil
+}
+
+// Checks whether the executed command changed the host, by default it always does
+func (shell *shellCommand) isChanged(result *execResult) (bool, error) {
+	if shell.ChangedWhen != nil {
+		return shell.ChangedWhen.evaluate(result)
+	}
+	return true, nil
+}
@@ -0,0 +1,85 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var DEFAULT_RETRY_DELAY time.Duration = 5 * time.Second
+
+// Remote exit code carried by the execution error, 0 on success and -1 when unknown
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var statusErr interface{ ExitStatus() int }
+	if errors.As(err, &statusErr) {
+		return statusErr.ExitStatus()
+	}
+	var codeErr interface{ ExitCode() int }
+	if errors.As(err, &codeErr) {
+		return codeErr.ExitCode()
+	}
+	return -1
+}
+
+func (shell *shellCommand) hasUntil() bool {
+	return shell.Until != nil || shell.UntilRc != nil
+}
+
+// Checks the until conditions against the attempt result, without conditions
+// the attempt is satisfied when the command succeeded
+func (shell *shellCommand) untilSatisfied(result *execResult, err error) bool {
+	if !shell.hasUntil() {
+		failed, errEval := shell.isFailed(result, err)
+		return errEval == nil && !failed
+	}
+	if shell.Until != nil && !shell.Until.Match(result.Output()) {
+		return false
+	}
+	if shell.UntilRc != nil && result.Rc != *shell.UntilRc {
+		return false
+	}
+	return true
+}
+
+// Executes the command until the until conditions are satisfied or the retries are exhausted,
+// timeout and stop errors are never retried
+func (shell *shellCommand) executeWithRetries(ctx context.Context, command string, item string) (*execResult, error) {
+	var attempts int = shell.Retries + 1
+	var result *execResult
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		result, err = shell.execute(ctx, command, item, shell.Stream)
+		if errors.Is(err, TIMEOUT_ERROR) || errors.Is(err, STOPPED_ERROR) {
+			return result, err
+		}
+		if shell.untilSatisfied(result, err) {
+			if shell.hasUntil() {
+				return result, nil
+			}
+			return result, err
+		}
+		if attempt < attempts {
+			if shell._logger != nil {
+				shell._logger.Debugf("Attempt %v of %v not satisfied, retrying in %s", attempt, attempts, shell.Delay.String())
+			}
+			select {
+			case <-ctx.Done():
+				return result, shell.contextError(ctx)
+			case <-time.After(shell.Delay):
+			}
+		}
+	}
+	if !shell.hasUntil() {
+		return result, err
+	}
+	var reason string = "Command condition not satisfied after " + strconv.Itoa(attempts) + " attempts, last exit code: " + strconv.Itoa(result.Rc)
+	if err != nil {
+		reason += ", last error: " + err.Error()
+	}
+	return result, errors.New(shell.mask(reason + ", last output: " + strings.TrimSpace(string(result.Output()))))
+}
@@ -0,0 +1,84 @@
+package shell
+
+import (
+	"errors"
+	"github.com/gookit/color"
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"github.com/hellgate75/go-deploy-modules/modules/privilege"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var DEFAULT_INTERPRETER string = "/bin/sh"
+
+// Renders the local script for the given list item and uploads it into a remote
+// folder private to the connecting user, the returned path must be removed by the
+// caller with removeScript. When run as another user than root, that user only is
+// granted read access through an ACL
+func (shell *shellCommand) uploadScript(item string) (string, error) {
+	bytesArr, err := ioutil.ReadFile(shell.Script)
+	if err != nil {
+		return "", errors.New("Unable to read script file " + shell.Script + ", cause: " + err.Error())
+	}
+	content := shell.substituteVars(strings.ReplaceAll(string(bytesArr), "{{ item }}", item))
+	file, err := ioutil.TempFile("", "go-deploy-script-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(content)
+	file.Close()
+	if err != nil {
+		return "", err
+	}
+	shell._lock.Lock()
+	shell._execCount++
+	dir := "/tmp/.go-deploy-" + shell.uuid + "-" + strconv.Itoa(shell._execCount) + ".d"
+	shell._lock.Unlock()
+	if err = privilege.MakePrivateDir(shell.client, dir); err != nil {
+		return "", err
+	}
+	remotePath := path.Join(dir, "script")
+	err = shell.client.FileTranfer().TransferFileAs(file.Name(), remotePath, 0700)
+	if err != nil {
+		shell.removeRemoteFile(dir)
+		return "", errors.New("Unable to upload script file " + shell.Script + ", cause: " + err.Error())
+	}
+	if user := shell.escalationUser(); user != "" && user != "root" {
+		output, errAcl := shell.client.Script("setfacl -m " + common.ShellQuote("u:"+user+":x") + " " + common.ShellQuote(dir) + " && setfacl -m " + common.ShellQuote("u:"+user+":r") + " " + common.ShellQuote(remotePath)).ExecuteWithFullOutput()
+		if errAcl != nil {
+			shell.removeRemoteFile(dir)
+			return "", errors.New("Unable to grant user " + user + " read access to the script file " + shell.Script + ", cause: " + errAcl.Error() + ", output: " + strings.TrimSpace(string(output)))
+		}
+	}
+	return remotePath, nil
+}
+
+// Removes the uploaded script together with its private folder
+func (shell *shellCommand) removeScript(remotePath string) {
+	shell.removeRemoteFile(path.Dir(remotePath))
+}
+
+// Removes a remote temporary file or folder, as uploaded scripts and standard input files
+func (shell *shellCommand) removeRemoteFile(remotePath string) {
+	_, err := shell.client.Script("rm -rf " + common.ShellQuote(remotePath)).ExecuteWithFullOutput()
+	if err != nil {
+		if shell._logger != nil {
+			shell._logger.Warnf("Unable to remove remote file: %s", remotePath)
+		} else {
+			color.LightYellow.Printf("Unable to remove remote file: %s\n", remotePath)
+		}
+	}
+}
+
+// Renders the interpreter command line running the uploaded script
+func (shell *shellCommand) renderScriptCommand(remotePath string, item string) string {
+	var parts []string = []string{shell.substituteVars(strings.ReplaceAll(shell.Interpreter, "{{ item }}", item)), common.ShellQuote(remotePath)}
+	for _, arg := range shell.ScriptArgs {
+		parts = append(parts, common.ShellQuote(shell.substituteVars(strings.ReplaceAll(arg, "{{ item }}", item))))
+	}
+	return shell.wrapEnvironment(strings.Join(parts, " "), item)
+}
@@ -0,0 +1,47 @@
+package shell
+
+import (
+	"errors"
+	"github.com/hellgate75/go-deploy-modules/modules/common"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Feeds the configured standard input to the escalated command: inline content and
+// local files are uploaded to a remote temporary file readable only by the connecting
+// user, which redirects it outside of the escalation. The returned remote path must
+// be removed by the caller when not empty
+func (shell *shellCommand) attachStdin(command string, item string) (string, string, error) {
+	var localPath string
+	if shell.Stdin != "" {
+		content := shell.substituteVars(strings.ReplaceAll(shell.Stdin, "{{ item }}", item))
+		file, err := ioutil.TempFile("", "go-deploy-stdin-*")
+		if err != nil {
+			return "", "", err
+		}
+		defer os.Remove(file.Name())
+		_, err = file.WriteString(content)
+		file.Close()
+		if err != nil {
+			return "", "", err
+		}
+		localPath = file.Name()
+	} else if shell.StdinFile != "" {
+		localPath = shell.substituteVars(strings.ReplaceAll(shell.StdinFile, "{{ item }}", item))
+		if _, err := os.Stat(localPath); err != nil {
+			return "", "", errors.New("Unable to read stdin file " + localPath + ", cause: " + err.Error())
+		}
+	} else {
+		return command, "", nil
+	}
+	shell._lock.Lock()
+	shell._execCount++
+	remotePath := "/tmp/.go-deploy-" + shell.uuid + "-" + strconv.Itoa(shell._execCount) + ".stdin"
+	shell._lock.Unlock()
+	if err := shell.client.FileTranfer().TransferFileAs(localPath, remotePath, 0600); err != nil {
+		return "", "", errors.New("Unable to upload stdin file " + localPath + ", cause: " + err.Error())
+	}
+	return "( " + command + " ) < " + common.ShellQuote(remotePath), remotePath, nil
+}
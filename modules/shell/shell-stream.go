@@ -0,0 +1,77 @@
+package shell
+
+import (
+	"bytes"
+	"github.com/gookit/color"
+	"io"
+	"strings"
+	"sync"
+)
+
+/*
+* Writer forwarding each complete line to the logger, while capturing all the output
+ */
+type lineStreamer struct {
+	capture io.Writer
+	emit    func(line string)
+	pending []byte
+	lock    sync.Mutex
+}
+
+func (streamer *lineStreamer) Write(p []byte) (int, error) {
+	streamer.lock.Lock()
+	defer streamer.lock.Unlock()
+	n, err := streamer.capture.Write(p)
+	streamer.pending = append(streamer.pending, p...)
+	for {
+		idx := bytes.IndexByte(streamer.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		streamer.emit(strings.TrimRight(string(streamer.pending[:idx]), "\r"))
+		streamer.pending = streamer.pending[idx+1:]
+	}
+	return n, err
+}
+
+// Emits the last line, when not terminated by a new line
+func (streamer *lineStreamer) Flush() {
+	streamer.lock.Lock()
+	defer streamer.lock.Unlock()
+	if len(streamer.pending) > 0 {
+		streamer.emit(strings.TrimRight(string(streamer.pending), "\r"))
+		streamer.pending = nil
+	}
+}
+
+// Creates the standard output and standard error streamers, prefixing lines
+// with the host name and the list item when present
+func (shell *shellCommand) newStreamers(stdout io.Writer, stderr io.Writer, item string) (*lineStreamer, *lineStreamer) {
+	var prefix string = "[" + shell.host.Name + "]"
+	if item != "" {
+		prefix += "[" + shell.mask(item) + "]"
+	}
+	outStreamer := &lineStreamer{
+		capture: stdout,
+		emit: func(line string) {
+			line = shell.mask(line)
+			if shell._logger != nil {
+				shell._logger.Infof("%s %s", prefix, line)
+			} else {
+				color.LightWhite.Printf("%s %s\n", prefix, line)
+			}
+		},
+	}
+	errStreamer := &lineStreamer{
+		capture: stderr,
+		emit: func(line string) {
+			line = shell.mask(line)
+			if shell._logger != nil {
+				shell._logger.Warnf("%s %s", prefix, line)
+			} else {
+				color.LightYellow.Printf("%s %s\n", prefix, line)
+			}
+		},
+	}
+	return outStreamer, errStreamer
+}